@@ -1,6 +1,9 @@
 package browserforge
 
 import (
+	"io/fs"
+	"time"
+
 	"github.com/yourneighborhoodchef/browserforge/fingerprint"
 )
 
@@ -14,8 +17,61 @@ type NavigatorFingerprint = fingerprint.NavigatorFingerprint
 
 type VideoCard = fingerprint.VideoCard
 
+type Geolocation = fingerprint.Geolocation
+
+type Header = fingerprint.Header
+
+type Headers = fingerprint.Headers
+
+type Browser = fingerprint.Browser
+
 type Option = fingerprint.Option
 
+type RequestContext = fingerprint.RequestContext
+
+type Session = fingerprint.Session
+
+type Destination = fingerprint.Destination
+
+type Finding = fingerprint.Finding
+
+type Severity = fingerprint.Severity
+
+type ConstraintError = fingerprint.ConstraintError
+
+type FieldError = fingerprint.FieldError
+
+type Filter = fingerprint.Filter
+
+type FilterStats = fingerprint.FilterStats
+
+var (
+	ErrUnknownBrowser          = fingerprint.ErrUnknownBrowser
+	ErrInvalidConstraint       = fingerprint.ErrInvalidConstraint
+	ErrConstraintUnsatisfiable = fingerprint.ErrConstraintUnsatisfiable
+	ErrMissingDataFile         = fingerprint.ErrMissingDataFile
+	ErrCorruptData             = fingerprint.ErrCorruptData
+	ErrCorruptNetwork          = fingerprint.ErrCorruptNetwork
+	ErrSamplingFailed          = fingerprint.ErrSamplingFailed
+	ErrRetryBudgetExhausted    = fingerprint.ErrRetryBudgetExhausted
+	ErrMissingField            = fingerprint.ErrMissingField
+	ErrInvalidField            = fingerprint.ErrInvalidField
+)
+
+func Retryable(err error) bool {
+	return fingerprint.Retryable(err)
+}
+
+const (
+	DestinationDocument = fingerprint.DestinationDocument
+	DestinationIframe   = fingerprint.DestinationIframe
+	DestinationImage    = fingerprint.DestinationImage
+	DestinationScript   = fingerprint.DestinationScript
+	DestinationStyle    = fingerprint.DestinationStyle
+	DestinationFont     = fingerprint.DestinationFont
+	DestinationEmpty    = fingerprint.DestinationEmpty
+)
+
 func New() (*Generator, error) {
 	return fingerprint.New()
 }
@@ -24,6 +80,18 @@ func NewWithOptions(opts ...Option) (*Generator, error) {
 	return fingerprint.NewWithOptions(opts...)
 }
 
+func Marshal(fp *Fingerprint) ([]byte, error) {
+	return fingerprint.Marshal(fp)
+}
+
+func Unmarshal(data []byte) (*Fingerprint, error) {
+	return fingerprint.Unmarshal(data)
+}
+
+func Validate(fp *Fingerprint) []Finding {
+	return fingerprint.Validate(fp)
+}
+
 func WithCustomUserAgent(userAgent string) Option {
 	return fingerprint.WithCustomUserAgent(userAgent)
 }
@@ -44,6 +112,58 @@ func WithOperatingSystem(os string) Option {
 	return fingerprint.WithOperatingSystem(os)
 }
 
+func WithHTTPVersion(version string) Option {
+	return fingerprint.WithHTTPVersion(version)
+}
+
+func WithLocales(locales ...string) Option {
+	return fingerprint.WithLocales(locales...)
+}
+
+func WithCountry(code string) Option {
+	return fingerprint.WithCountry(code)
+}
+
+func WithGeolocation() Option {
+	return fingerprint.WithGeolocation()
+}
+
+func WithReferenceTime(t time.Time) Option {
+	return fingerprint.WithReferenceTime(t)
+}
+
+func WithStrict() Option {
+	return fingerprint.WithStrict()
+}
+
+func WithFilter(filter Filter) Option {
+	return fingerprint.WithFilter(filter)
+}
+
+func WithRetryBudget(attempts int) Option {
+	return fingerprint.WithRetryBudget(attempts)
+}
+
+func ScreenAtLeast(width, height int) Filter {
+	return fingerprint.ScreenAtLeast(width, height)
+}
+
+func ScreenAtMost(width, height int) Filter {
+	return fingerprint.ScreenAtMost(width, height)
+}
+
+func DeviceMemoryAtLeast(gb int) Filter {
+	return fingerprint.DeviceMemoryAtLeast(gb)
+}
+
+func HardwareConcurrencyAtLeast(n int) Filter {
+	return fingerprint.HardwareConcurrencyAtLeast(n)
+}
+
+func RendererContains(substr string) Filter {
+	return fingerprint.RendererContains(substr)
+}
+
 func WithCamoufoxConstraints() Option {
 	return fingerprint.WithCamoufoxConstraints()
 }
@@ -55,3 +175,37 @@ func WithScreenConstraints(maxWidth, maxHeight int) Option {
 func WithWindowSize(width, height int) Option {
 	return fingerprint.WithWindowSize(width, height)
 }
+
+type DataSource = fingerprint.DataSource
+
+func WithDataSource(src DataSource) Option {
+	return fingerprint.WithDataSource(src)
+}
+
+func WithDataFS(fsys fs.FS) Option {
+	return fingerprint.WithDataFS(fsys)
+}
+
+func WithDataDir(path string) Option {
+	return fingerprint.WithDataDir(path)
+}
+
+func WithDataFallback() Option {
+	return fingerprint.WithDataFallback()
+}
+
+func WithBrowsers(browsers ...string) Option {
+	return fingerprint.WithBrowsers(browsers...)
+}
+
+func WithBrowserSpecs(specs ...Browser) Option {
+	return fingerprint.WithBrowserSpecs(specs...)
+}
+
+func WithOperatingSystems(systems ...string) Option {
+	return fingerprint.WithOperatingSystems(systems...)
+}
+
+func WithDeviceCategories(categories ...string) Option {
+	return fingerprint.WithDeviceCategories(categories...)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"os"
 
@@ -10,12 +11,26 @@ import (
 
 func main() {
 	if len(os.Args) < 2 {
-		fmt.Fprintf(os.Stderr, "Usage: %s [headers|fingerprint|all]\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [headers|fingerprint|all|stats|validate-network] [-data dir]\n", os.Args[0])
 		os.Exit(1)
 	}
 	cmd := os.Args[1]
+	switch cmd {
+	case "stats":
+		os.Exit(runStats(os.Args[2:], os.Stdout, os.Stderr))
+	case "validate-network":
+		os.Exit(runValidateNetwork(os.Args[2:], os.Stdout, os.Stderr))
+	}
 
-	generator, err := fingerprint.New()
+	fs := flag.NewFlagSet(cmd, flag.ExitOnError)
+	dataDir := fs.String("data", "", "directory with fingerprint-network.json and any network files overriding the embedded ones")
+	fs.Parse(os.Args[2:])
+
+	var opts []fingerprint.Option
+	if *dataDir != "" {
+		opts = append(opts, fingerprint.WithDataDir(*dataDir), fingerprint.WithDataFallback())
+	}
+	generator, err := fingerprint.NewWithOptions(opts...)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error initializing generator: %v\n", err)
 		os.Exit(1)
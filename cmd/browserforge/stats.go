@@ -0,0 +1,140 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/bayesian"
+	"github.com/yourneighborhoodchef/browserforge/internal/data"
+)
+
+// evidenceFlag collects repeated -given node=value|value flags.
+type evidenceFlag map[string][]string
+
+func (e evidenceFlag) String() string {
+	var parts []string
+	for _, name := range sortedNames(e) {
+		parts = append(parts, name+"="+strings.Join(e[name], "|"))
+	}
+	return strings.Join(parts, " ")
+}
+
+func (e evidenceFlag) Set(s string) error {
+	name, values, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("want node=value, got %q", s)
+	}
+	e[name] = append(e[name], strings.Split(values, "|")...)
+	return nil
+}
+
+func sortedNames(m map[string][]string) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var networkLoaders = map[string]func(data.Source) (*bayesian.BayesianNetwork, error){
+	"fingerprint": bayesian.LoadFingerprintNetwork,
+	"header":      bayesian.LoadHeaderNetwork,
+	"input":       bayesian.LoadInputNetwork,
+}
+
+func runStats(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	evidence := evidenceFlag{}
+	network := fs.String("network", "fingerprint", "network to query: fingerprint, header or input")
+	dataDir := fs.String("data", "", "directory with network files overriding the embedded ones")
+	top := fs.Int("top", 20, "number of values to list per node, 0 for all")
+	fs.Var(evidence, "given", "evidence as node=value, with alternatives separated by |; repeatable")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: browserforge stats [flags] [node...]\n\n")
+		fmt.Fprintf(stderr, "Prints the probability of the evidence and the distribution of each node given it.\n")
+		fmt.Fprintf(stderr, "Without nodes, lists the nodes of the network.\n\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	load, ok := networkLoaders[*network]
+	if !ok {
+		fmt.Fprintf(stderr, "Unknown network: %s\n", *network)
+		return 2
+	}
+	src := data.Embedded
+	if *dataDir != "" {
+		dir, err := data.FromDir(*dataDir)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error opening data directory: %v\n", err)
+			return 1
+		}
+		src = data.Overlay(dir, data.Embedded)
+	}
+	bn, err := load(src)
+	if err != nil {
+		fmt.Fprintf(stderr, "Error loading network: %v\n", err)
+		return 1
+	}
+
+	if fs.NArg() == 0 && len(evidence) == 0 {
+		for _, node := range bn.Nodes() {
+			fmt.Fprintf(stdout, "%-28s %4d values  parents: %s\n", node.Name(), len(node.PossibleValues()), strings.Join(node.ParentNames(), ", "))
+		}
+		return 0
+	}
+
+	if len(evidence) > 0 {
+		p, err := bn.Probability(evidence)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "P(%s) = %.6f\n", evidence, p)
+	}
+	for _, name := range fs.Args() {
+		dist, err := bn.Marginal(name, evidence)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error: %v\n", err)
+			return 1
+		}
+		values := make([]string, 0, len(dist))
+		for v := range dist {
+			values = append(values, v)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			if dist[values[i]] != dist[values[j]] {
+				return dist[values[i]] > dist[values[j]]
+			}
+			return values[i] < values[j]
+		})
+		fmt.Fprintln(stdout)
+		if len(evidence) > 0 {
+			fmt.Fprintf(stdout, "%s | %s\n", name, evidence)
+		} else {
+			fmt.Fprintln(stdout, name)
+		}
+		shown := values
+		if *top > 0 && len(shown) > *top {
+			shown = shown[:*top]
+		}
+		for _, v := range shown {
+			fmt.Fprintf(stdout, "  %7.3f%%  %s\n", 100*dist[v], v)
+		}
+		if rest := len(values) - len(shown); rest > 0 {
+			other := 0.0
+			for _, v := range values[len(shown):] {
+				other += dist[v]
+			}
+			fmt.Fprintf(stdout, "  %7.3f%%  (%d more values)\n", 100*other, rest)
+		}
+	}
+	return 0
+}
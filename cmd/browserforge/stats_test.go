@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// weatherJSON is a network small enough to work its statistics out by hand.
+const weatherJSON = `{"nodes": [
+	{"name": "Rain", "parentNames": [], "possibleValues": ["yes", "no"],
+	 "conditionalProbabilities": {"yes": 0.2, "no": 0.8}},
+	{"name": "Sprinkler", "parentNames": ["Rain"], "possibleValues": ["on", "off"],
+	 "conditionalProbabilities": {"deeper": {
+		"yes": {"on": 0.01, "off": 0.99},
+		"no": {"on": 0.4, "off": 0.6}}}},
+	{"name": "Grass", "parentNames": ["Sprinkler", "Rain"], "possibleValues": ["wet", "dry"],
+	 "conditionalProbabilities": {"deeper": {
+		"on": {"deeper": {"yes": {"wet": 0.99, "dry": 0.01}, "no": {"wet": 0.9, "dry": 0.1}}},
+		"off": {"deeper": {"yes": {"wet": 0.8, "dry": 0.2}}, "skip": {"dry": 1}}}}}
+]}`
+
+func runStatsOnWeather(t *testing.T, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "fingerprint-network.json"), []byte(weatherJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var out, errOut bytes.Buffer
+	code = runStats(append([]string{"-data", dir}, args...), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestStats(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		want string
+	}{
+		{nil, "" +
+			"Rain                            2 values  parents: \n" +
+			"Sprinkler                       2 values  parents: Rain\n" +
+			"Grass                           2 values  parents: Sprinkler, Rain\n"},
+		// P(wet) = .2(.01*.99 + .99*.8) + .8(.4*.9) = .44838
+		{[]string{"Grass"}, "\n" +
+			"Grass\n" +
+			"   55.162%  dry\n" +
+			"   44.838%  wet\n"},
+		{[]string{"-given", "Rain=yes", "Grass"}, "" +
+			"P(Rain=yes) = 0.200000\n\n" +
+			"Grass | Rain=yes\n" +
+			"   80.190%  wet\n" +
+			"   19.810%  dry\n"},
+		// P(Rain=yes | wet) = .16038 / .44838
+		{[]string{"-given", "Grass=wet", "-top", "1", "Rain"}, "" +
+			"P(Grass=wet) = 0.448380\n\n" +
+			"Rain | Grass=wet\n" +
+			"   64.231%  no\n" +
+			"   35.769%  (1 more values)\n"},
+		{[]string{"-given", "Rain=yes|no", "-given", "Sprinkler=on"}, "" +
+			"P(Rain=yes|no Sprinkler=on) = 0.322000\n"},
+	} {
+		code, stdout, stderr := runStatsOnWeather(t, tc.args...)
+		if code != 0 || stdout != tc.want {
+			t.Errorf("stats %v: exit %d, stderr %q, output\n%s\nwant\n%s", tc.args, code, stderr, stdout, tc.want)
+		}
+	}
+}
+
+func TestStatsErrors(t *testing.T) {
+	for _, tc := range []struct {
+		args []string
+		code int
+		want string
+	}{
+		{[]string{"Hail"}, 1, "Hail"},
+		{[]string{"-given", "Grass=soaked", "Rain"}, 1, "soaked"},
+		{[]string{"-network", "weather"}, 2, "Unknown network"},
+		{[]string{"-given", "Grass"}, 2, "want node=value"},
+	} {
+		code, _, stderr := runStatsOnWeather(t, tc.args...)
+		if code != tc.code || !strings.Contains(stderr, tc.want) {
+			t.Errorf("stats %v: exit %d, stderr %q; want exit %d mentioning %q", tc.args, code, stderr, tc.code, tc.want)
+		}
+	}
+}
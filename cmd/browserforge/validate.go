@@ -0,0 +1,54 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/bayesian"
+)
+
+func runValidateNetwork(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("validate-network", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: browserforge validate-network <file>...\n\n")
+		fmt.Fprintf(stderr, "Checks network files for unknown or misordered parents, tables that do not\n")
+		fmt.Fprintf(stderr, "match the parents or their values, and probabilities that are negative or\n")
+		fmt.Fprintf(stderr, "do not sum to 1.\n")
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	status := 0
+	for _, path := range fs.Args() {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error reading network: %v\n", err)
+			status = 1
+			continue
+		}
+		bn, err := bayesian.ParseNetwork(raw)
+		if err != nil {
+			fmt.Fprintf(stdout, "%s: %v\n", path, err)
+			status = 1
+			continue
+		}
+		problems := bn.Validate()
+		for _, p := range problems {
+			fmt.Fprintf(stdout, "%s: %v\n", path, p)
+		}
+		if len(problems) > 0 {
+			status = 1
+			continue
+		}
+		fmt.Fprintf(stdout, "%s: ok, %d nodes\n", path, len(bn.Nodes()))
+	}
+	return status
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"log"
 
@@ -9,8 +10,10 @@ import (
 )
 
 func main() {
+	dataDir := flag.String("data", ".", "directory holding fingerprint-network.json")
+	flag.Parse()
 
-	generator, err := fingerprint.New()
+	generator, err := fingerprint.NewWithOptions(fingerprint.WithDataDir(*dataDir), fingerprint.WithDataFallback())
 	if err != nil {
 		log.Fatalf("Error initializing fingerprint generator: %v", err)
 	}
@@ -34,6 +37,8 @@ func main() {
 	fmt.Println(string(headersJSON))
 
 	customGenerator, err := fingerprint.NewWithOptions(
+		fingerprint.WithDataDir(*dataDir),
+		fingerprint.WithDataFallback(),
 		fingerprint.WithBrowser("chrome"),
 		fingerprint.WithOperatingSystem("windows"),
 	)
@@ -54,13 +59,15 @@ func main() {
 	}
 
 	fmt.Println("\nFew HTTP Headers:")
-	for key, value := range customFp.Headers {
-		if key == "User-Agent" || key == "Accept-Language" || key == "Accept" {
-			fmt.Printf("%s: %s\n", key, value)
+	for _, header := range customFp.Headers {
+		if header.Name == "User-Agent" || header.Name == "Accept-Language" || header.Name == "Accept" {
+			fmt.Printf("%s: %s\n", header.Name, header.Value)
 		}
 	}
 
 	camoufoxGenerator, err := fingerprint.NewWithOptions(
+		fingerprint.WithDataDir(*dataDir),
+		fingerprint.WithDataFallback(),
 		fingerprint.WithCamoufoxConstraints(),
 	)
 	if err != nil {
@@ -89,6 +96,8 @@ func main() {
 	fmt.Printf("Window Position: (%d, %d)\n", camoufoxFp.Screen.ScreenX, camoufoxFp.Screen.PageYOffset)
 
 	constrainedGenerator, err := fingerprint.NewWithOptions(
+		fingerprint.WithDataDir(*dataDir),
+		fingerprint.WithDataFallback(),
 		fingerprint.WithCamoufoxConstraints(),
 		fingerprint.WithScreenConstraints(1920, 1080),
 		fingerprint.WithWindowSize(1200, 800),
@@ -0,0 +1,80 @@
+package fingerprint
+
+import (
+	"context"
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// GenerateBatch generates n fingerprints using up to workers goroutines. The
+// per-fingerprint random sources are drawn up front, so a seeded Generator
+// returns the same batch regardless of scheduling. A workers value <= 0 uses
+// GOMAXPROCS. Canceling ctx stops handing out fingerprints to generate; once
+// all n have been handed out, the batch is returned when they finish.
+func (g *Generator) GenerateBatch(ctx context.Context, n, workers int) ([]*Fingerprint, error) {
+	if n <= 0 {
+		return nil, nil
+	}
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	if workers > n {
+		workers = n
+	}
+
+	seeds := make([]int64, n)
+	g.mu.Lock()
+	for i := range seeds {
+		seeds[i] = g.rng.Int63()
+	}
+	g.mu.Unlock()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make([]*Fingerprint, n)
+	jobs := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fp, err := g.generate(rand.New(rand.NewSource(seeds[i])))
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = err
+						cancel()
+					})
+					return
+				}
+				results[i] = fp
+			}
+		}()
+	}
+
+	fed := 0
+feed:
+	for ; fed < n; fed++ {
+		select {
+		case jobs <- fed:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if fed < n {
+		return nil, ctx.Err()
+	}
+	return results, nil
+}
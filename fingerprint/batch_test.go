@@ -0,0 +1,161 @@
+package fingerprint
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestConcurrentUse shares one Generator between goroutines calling Generate,
+// GenerateBatch, GenerateHeadersOnly and Score. Run it with -race.
+func TestConcurrentUse(t *testing.T) {
+	g := newTestGenerator(t, WithCountry("US"), WithFilter(ScreenAtLeast(320, 320)))
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for w := 0; w < 8; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 20; i++ {
+				fp, err := g.Generate()
+				if err != nil {
+					errs <- err
+					return
+				}
+				fp.Headers.Set("User-Agent", "changed")
+				if _, err := g.Score(fp); err != nil {
+					errs <- err
+					return
+				}
+				if _, err := g.GenerateHeadersOnly(); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	for w := 0; w < 2; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fps, err := g.GenerateBatch(context.Background(), 50, 4)
+			if err != nil {
+				errs <- err
+				return
+			}
+			for _, fp := range fps {
+				if fp == nil {
+					errs <- fmt.Errorf("batch has a nil fingerprint")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if stats := g.FilterStats(); stats.Accepted != 8*20+2*50 {
+		t.Errorf("filter accepted %d fingerprints, want %d", stats.Accepted, 8*20+2*50)
+	}
+}
+
+func TestGenerateBatchIndependentOfWorkers(t *testing.T) {
+	var want []byte
+	for _, workers := range []int{1, 3, 8} {
+		g := newTestGenerator(t, WithSeed(5))
+		fps, err := g.GenerateBatch(context.Background(), 24, workers)
+		if err != nil {
+			t.Fatalf("GenerateBatch: %v", err)
+		}
+		got, err := json.Marshal(fps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want == nil {
+			want = got
+		} else if string(got) != string(want) {
+			t.Errorf("batch with %d workers differs from the one with 1", workers)
+		}
+	}
+}
+
+func TestGenerateBatchCanceled(t *testing.T) {
+	g := newTestGenerator(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := g.GenerateBatch(ctx, 100, 4); err != context.Canceled {
+		t.Errorf("GenerateBatch error = %v, want context.Canceled", err)
+	}
+}
+
+func TestGenerateBatchCanceledAfterLastJob(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// With one worker the last fingerprint is handed out before it is
+	// generated, so canceling while checking it leaves a complete batch.
+	var calls atomic.Int64
+	g := newTestGenerator(t, WithSeed(1), WithFilter(func(*Fingerprint) bool {
+		if calls.Add(1) == 3 {
+			cancel()
+		}
+		return true
+	}))
+	fps, err := g.GenerateBatch(ctx, 3, 1)
+	if err != nil {
+		t.Fatalf("GenerateBatch: %v", err)
+	}
+	for i, fp := range fps {
+		if fp == nil {
+			t.Errorf("fingerprint %d missing", i)
+		}
+	}
+	if len(fps) != 3 {
+		t.Errorf("got %d fingerprints, want 3", len(fps))
+	}
+}
+
+func BenchmarkGenerate(b *testing.B) {
+	g := newTestGenerator(b)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := g.Generate(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenerateParallel(b *testing.B) {
+	g := newTestGenerator(b)
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := g.Generate(); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkGenerateBatch reports the time per fingerprint for growing worker
+// counts; it should fall as workers are added, up to GOMAXPROCS.
+func BenchmarkGenerateBatch(b *testing.B) {
+	const n = 64
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			g := newTestGenerator(b)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := g.GenerateBatch(context.Background(), n, workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/fingerprint")
+		})
+	}
+}
@@ -3,6 +3,7 @@ package fingerprint
 import (
 	"math/rand"
 	"regexp"
+	"strings"
 )
 
 func applyScreenConstraints(screen *ScreenFingerprint, constraints *ScreenConstraints) {
@@ -51,7 +52,7 @@ func applyWindowSize(screen *ScreenFingerprint, windowSize *WindowSize) {
 	screen.OuterHeight = windowSize.Height
 }
 
-func handleScreenPositioning(screen *ScreenFingerprint) {
+func handleScreenPositioning(rng *rand.Rand, screen *ScreenFingerprint) {
 	sx := screen.ScreenX
 
 	if sx == 0 {
@@ -71,10 +72,10 @@ func handleScreenPositioning(screen *ScreenFingerprint) {
 	if maxY == 0 {
 		screen.PageYOffset = 0
 	} else if maxY > 0 {
-		screen.PageYOffset = rand.Intn(maxY)
+		screen.PageYOffset = rng.Intn(maxY)
 	} else {
 
-		screen.PageYOffset = maxY + rand.Intn(-maxY)
+		screen.PageYOffset = maxY + rng.Intn(-maxY)
 	}
 }
 
@@ -121,27 +122,33 @@ func filterFalsyValues(fp *Fingerprint) {
 	}
 }
 
+var firefoxVersionRe = regexp.MustCompile(`(^|\D)(1[0-9]{2})(\.[0-9]+)`)
+
+// rewriteFirefoxVersion replaces the major version of every Firefox version
+// number in s with realVersion.
+func rewriteFirefoxVersion(s, realVersion string) string {
+	return firefoxVersionRe.ReplaceAllString(s, "${1}"+realVersion+"${3}")
+}
+
 func updateFirefoxVersion(fp *Fingerprint, realVersion string) {
 
 	if realVersion == "" {
 		return
 	}
 
-	re := regexp.MustCompile(`(?<!\d)(1[0-9]{2})(\.[0-9]+)(?!\d)`)
+	fp.Navigator.UserAgent = rewriteFirefoxVersion(fp.Navigator.UserAgent, realVersion)
 
-	fp.Navigator.UserAgent = re.ReplaceAllString(fp.Navigator.UserAgent, realVersion+"$2")
-
-	fp.Navigator.AppVersion = re.ReplaceAllString(fp.Navigator.AppVersion, realVersion+"$2")
+	fp.Navigator.AppVersion = rewriteFirefoxVersion(fp.Navigator.AppVersion, realVersion)
 
 	if fp.Navigator.Oscpu != nil {
-		*fp.Navigator.Oscpu = re.ReplaceAllString(*fp.Navigator.Oscpu, realVersion+"$2")
+		*fp.Navigator.Oscpu = rewriteFirefoxVersion(*fp.Navigator.Oscpu, realVersion)
 	}
 }
 
 func whitelistProperties(fp *Fingerprint, whitelist PropertyWhitelist) *Fingerprint {
 
 	result := &Fingerprint{
-		Headers: make(map[string]string),
+		Headers: filterHeaders(fp.Headers, whitelist.Headers),
 		Battery: make(map[string]interface{}),
 	}
 
@@ -214,12 +221,6 @@ func whitelistProperties(fp *Fingerprint, whitelist PropertyWhitelist) *Fingerpr
 	}
 	result.Navigator = navigatorData
 
-	for _, header := range whitelist.Headers {
-		if value, exists := fp.Headers[header]; exists {
-			result.Headers[header] = value
-		}
-	}
-
 	if fp.Battery != nil {
 		for _, prop := range whitelist.Battery {
 			if value, exists := fp.Battery[prop]; exists {
@@ -231,6 +232,19 @@ func whitelistProperties(fp *Fingerprint, whitelist PropertyWhitelist) *Fingerpr
 	return result
 }
 
+func filterHeaders(hdrs Headers, allowed []string) Headers {
+	result := Headers{}
+	for _, hdr := range hdrs {
+		for _, name := range allowed {
+			if strings.EqualFold(hdr.Name, name) {
+				result = append(result, hdr)
+				break
+			}
+		}
+	}
+	return result
+}
+
 func max(a, b int) int {
 	if a > b {
 		return a
@@ -238,19 +252,14 @@ func max(a, b int) int {
 	return b
 }
 
+// extractFirefoxVersion returns the major Firefox version in userAgent, the
+// form updateFirefoxVersion takes.
 func extractFirefoxVersion(userAgent string) string {
-
-	re := regexp.MustCompile(`Firefox/(\d+\.\d+)`)
+	re := regexp.MustCompile(`Firefox/(\d+)`)
 	matches := re.FindStringSubmatch(userAgent)
 	if len(matches) >= 2 {
 		return matches[1]
 	}
 
-	re = regexp.MustCompile(`Firefox/(\d+)`)
-	matches = re.FindStringSubmatch(userAgent)
-	if len(matches) >= 2 {
-		return matches[1]
-	}
-
 	return ""
 }
@@ -0,0 +1,21 @@
+package fingerprint
+
+import "testing"
+
+func TestUpdateFirefoxVersion(t *testing.T) {
+	oscpu := "Windows NT 10.0; Win64; x64"
+	fp := &Fingerprint{Navigator: NavigatorFingerprint{
+		UserAgent:  "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0",
+		AppVersion: "5.0 (Windows)",
+		Oscpu:      &oscpu,
+	}}
+	updateFirefoxVersion(fp, "135")
+
+	want := "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:135.0) Gecko/20100101 Firefox/135.0"
+	if fp.Navigator.UserAgent != want {
+		t.Errorf("user agent = %q, want %q", fp.Navigator.UserAgent, want)
+	}
+	if *fp.Navigator.Oscpu != oscpu {
+		t.Errorf("oscpu = %q, want it unchanged", *fp.Navigator.Oscpu)
+	}
+}
@@ -0,0 +1,81 @@
+package fingerprint
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/bayesian"
+	"github.com/yourneighborhoodchef/browserforge/internal/data"
+	"github.com/yourneighborhoodchef/browserforge/internal/headers"
+)
+
+// Errors returned by the generator wrap one of these, so that callers can
+// tell configuration mistakes, which fail the same way every time, from
+// sampling failures, which a retry may avoid. See Retryable.
+var (
+	// ErrUnknownBrowser is returned for browser names the networks do not
+	// know.
+	ErrUnknownBrowser = headers.ErrUnknownBrowser
+	// ErrInvalidConstraint is returned for operating systems, devices,
+	// countries and HTTP versions the networks do not know.
+	ErrInvalidConstraint = headers.ErrInvalidConstraint
+	// ErrMissingDataFile is returned when a data source lacks a model file.
+	ErrMissingDataFile = data.ErrMissingFile
+	// ErrCorruptData is returned for model files that cannot be decoded.
+	ErrCorruptData = data.ErrCorruptFile
+	// ErrCorruptNetwork is returned for network definitions that fail
+	// validation and for definitions, or values sampled from them, that
+	// cannot be decoded. It wraps ErrCorruptData.
+	ErrCorruptNetwork = bayesian.ErrCorruptNetwork
+	// ErrSamplingFailed is returned when a sample cannot be turned into a
+	// fingerprint. Generating again may succeed.
+	ErrSamplingFailed = bayesian.ErrSamplingFailed
+	// ErrRetryBudgetExhausted is returned when no fingerprint passed the
+	// filters within the retry budget. It wraps ErrSamplingFailed.
+	ErrRetryBudgetExhausted = fmt.Errorf("retry budget exhausted: %w", ErrSamplingFailed)
+	// ErrConstraintUnsatisfiable is returned, often wrapped in a
+	// *ConstraintError, when the networks cannot produce a fingerprint with
+	// the requested values.
+	ErrConstraintUnsatisfiable = bayesian.ErrConstraintUnsatisfiable
+)
+
+// ConstraintError names the network node and value that could not be
+// satisfied.
+type ConstraintError = bayesian.ConstraintError
+
+var (
+	// ErrMissingField is returned, wrapped in a *FieldError, when a
+	// fingerprint lacks a field it cannot be used without.
+	ErrMissingField = errors.New("missing required field")
+	// ErrInvalidField is returned, wrapped in a *FieldError, when a sampled
+	// value cannot be parsed.
+	ErrInvalidField = errors.New("invalid field value")
+)
+
+// FieldError reports a required field that is missing, or a field whose
+// sampled Value cannot be parsed. Field is the field's JSON path. It unwraps
+// to ErrInvalidField when Value is set and to ErrMissingField otherwise.
+type FieldError struct {
+	Field string
+	Value string
+}
+
+func (e *FieldError) Error() string {
+	if e.Value != "" {
+		return fmt.Sprintf("invalid fingerprint: cannot parse %s value %q", e.Field, e.Value)
+	}
+	return fmt.Sprintf("invalid fingerprint: %v %s", ErrMissingField, e.Field)
+}
+
+func (e *FieldError) Unwrap() error {
+	if e.Value != "" {
+		return ErrInvalidField
+	}
+	return ErrMissingField
+}
+
+// Retryable reports whether err is a sampling failure that generating again
+// may avoid, rather than a problem with the generator's options or data.
+func Retryable(err error) bool {
+	return errors.Is(err, ErrSamplingFailed)
+}
@@ -0,0 +1,94 @@
+package fingerprint
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+const defaultRetryBudget = 100
+
+// Filter reports whether a generated fingerprint is acceptable. Filters may
+// be called concurrently and must not modify the fingerprint.
+type Filter func(fp *Fingerprint) bool
+
+// ScreenAtLeast accepts screens at least width by height pixels.
+func ScreenAtLeast(width, height int) Filter {
+	return func(fp *Fingerprint) bool {
+		return fp.Screen.Width >= width && fp.Screen.Height >= height
+	}
+}
+
+// ScreenAtMost accepts screens at most width by height pixels. Unlike
+// WithScreenConstraints it rejects larger screens rather than shrinking them.
+func ScreenAtMost(width, height int) Filter {
+	return func(fp *Fingerprint) bool {
+		return fp.Screen.Width <= width && fp.Screen.Height <= height
+	}
+}
+
+// DeviceMemoryAtLeast accepts fingerprints reporting at least gb gigabytes of
+// navigator.deviceMemory. Browsers that do not report it, such as Firefox
+// and Safari, are rejected.
+func DeviceMemoryAtLeast(gb int) Filter {
+	return func(fp *Fingerprint) bool {
+		return fp.Navigator.DeviceMemory != nil && *fp.Navigator.DeviceMemory >= gb
+	}
+}
+
+// HardwareConcurrencyAtLeast accepts fingerprints with at least n logical
+// processors.
+func HardwareConcurrencyAtLeast(n int) Filter {
+	return func(fp *Fingerprint) bool {
+		return fp.Navigator.HardwareConcurrency >= n
+	}
+}
+
+// RendererContains accepts fingerprints whose WebGL renderer contains substr,
+// compared case-insensitively.
+func RendererContains(substr string) Filter {
+	substr = strings.ToLower(substr)
+	return func(fp *Fingerprint) bool {
+		return fp.VideoCard != nil && strings.Contains(strings.ToLower(fp.VideoCard.Renderer), substr)
+	}
+}
+
+func (g *Generator) accept(fp *Fingerprint) bool {
+	for _, f := range g.filters {
+		if !f(fp) {
+			return false
+		}
+	}
+	return true
+}
+
+type filterCounters struct {
+	attempts atomic.Uint64
+	accepted atomic.Uint64
+}
+
+// FilterStats counts the fingerprints checked against a generator's filters
+// and how many of them passed.
+type FilterStats struct {
+	Attempts uint64
+	Accepted uint64
+}
+
+// AcceptanceRate returns the share of checked fingerprints that passed, or 0
+// before any was checked.
+func (s FilterStats) AcceptanceRate() float64 {
+	if s.Attempts == 0 {
+		return 0
+	}
+	return float64(s.Accepted) / float64(s.Attempts)
+}
+
+// FilterStats returns the counts since the generator was created. Generators
+// without filters report zero.
+func (g *Generator) FilterStats() FilterStats {
+	// Accepted is read first so that it never exceeds Attempts.
+	accepted := g.stats.accepted.Load()
+	return FilterStats{
+		Attempts: g.stats.attempts.Load(),
+		Accepted: accepted,
+	}
+}
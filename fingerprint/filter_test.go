@@ -0,0 +1,97 @@
+package fingerprint
+
+import (
+	"errors"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestRetryBudgetExhausted(t *testing.T) {
+	for _, tt := range []struct {
+		opts []Option
+		want int
+	}{
+		{[]Option{WithRetryBudget(7)}, 7},
+		{nil, defaultRetryBudget},
+	} {
+		var calls atomic.Int64
+		never := func(*Fingerprint) bool {
+			calls.Add(1)
+			return false
+		}
+		g := newTestGenerator(t, append(tt.opts, WithSeed(1), WithFilter(never))...)
+		fp, err := g.Generate()
+		if fp != nil || !errors.Is(err, ErrRetryBudgetExhausted) {
+			t.Errorf("Generate = %v, %v, want ErrRetryBudgetExhausted", fp, err)
+		}
+		if !Retryable(err) {
+			t.Errorf("%v is not retryable", err)
+		}
+		if n := calls.Load(); n != int64(tt.want) {
+			t.Errorf("filter called %d times, want %d", n, tt.want)
+		}
+		stats := g.FilterStats()
+		if stats.Attempts != uint64(tt.want) || stats.Accepted != 0 || stats.AcceptanceRate() != 0 {
+			t.Errorf("FilterStats = %+v, rate %v, want %d attempts and none accepted", stats, stats.AcceptanceRate(), tt.want)
+		}
+	}
+}
+
+func TestAcceptanceRate(t *testing.T) {
+	g := newTestGenerator(t, WithSeed(1))
+	if stats := g.FilterStats(); stats != (FilterStats{}) || stats.AcceptanceRate() != 0 {
+		t.Errorf("FilterStats without filters = %+v", stats)
+	}
+
+	g = newTestGenerator(t, WithSeed(1), WithFilter(func(*Fingerprint) bool { return true }))
+	for i := 0; i < 10; i++ {
+		if _, err := g.Generate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := g.FilterStats()
+	if stats.Attempts != 10 || stats.Accepted != 10 || stats.AcceptanceRate() != 1 {
+		t.Errorf("FilterStats = %+v, rate %v, want 10 of 10", stats, stats.AcceptanceRate())
+	}
+}
+
+func TestDeclarativeFilters(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		filter Filter
+		holds  func(fp *Fingerprint) bool
+	}{
+		{"ScreenAtLeast", ScreenAtLeast(1920, 1080), func(fp *Fingerprint) bool {
+			return fp.Screen.Width >= 1920 && fp.Screen.Height >= 1080
+		}},
+		{"ScreenAtMost", ScreenAtMost(1536, 900), func(fp *Fingerprint) bool {
+			return fp.Screen.Width <= 1536 && fp.Screen.Height <= 900
+		}},
+		{"DeviceMemoryAtLeast", DeviceMemoryAtLeast(8), func(fp *Fingerprint) bool {
+			return fp.Navigator.DeviceMemory != nil && *fp.Navigator.DeviceMemory >= 8
+		}},
+		{"HardwareConcurrencyAtLeast", HardwareConcurrencyAtLeast(12), func(fp *Fingerprint) bool {
+			return fp.Navigator.HardwareConcurrency >= 12
+		}},
+		{"RendererContains", RendererContains("nvidia"), func(fp *Fingerprint) bool {
+			return fp.VideoCard != nil && strings.Contains(fp.VideoCard.Renderer, "NVIDIA")
+		}},
+	} {
+		g := newTestGenerator(t, WithSeed(1), WithFilter(tt.filter))
+		for i := 0; i < 20; i++ {
+			fp, err := g.Generate()
+			if err != nil {
+				t.Fatalf("%s: %v", tt.name, err)
+			}
+			if !tt.holds(fp) {
+				t.Errorf("%s let through screen %dx%d, memory %v, %d cores, video card %+v", tt.name,
+					fp.Screen.Width, fp.Screen.Height, fp.Navigator.DeviceMemory, fp.Navigator.HardwareConcurrency, fp.VideoCard)
+			}
+		}
+		// A filter every sample passes would not show that it constrains.
+		if rate := g.FilterStats().AcceptanceRate(); rate == 1 {
+			t.Errorf("%s accepted every sample", tt.name)
+		}
+	}
+}
@@ -1,25 +1,50 @@
 package fingerprint
 
 import (
+	cryptorand "crypto/rand"
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"math/rand"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/yourneighborhoodchef/browserforge/internal/bayesian"
+	"github.com/yourneighborhoodchef/browserforge/internal/data"
+	"github.com/yourneighborhoodchef/browserforge/internal/geo"
 	"github.com/yourneighborhoodchef/browserforge/internal/headers"
 )
 
+// DataSource supplies the network models and helper files by name
+// (input-network.json, header-network.json, fingerprint-network.json,
+// headers-order.json, browser-helper-file.json and country-zones.json).
+type DataSource = data.Source
+
+// Generator is safe for concurrent use by multiple goroutines. Options are
+// applied at construction time and must not be changed while generating.
 type Generator struct {
 	network           *bayesian.BayesianNetwork
+	networkErr        error
 	headers           *headers.HeaderGenerator
+	dataSource        DataSource
+	dataFallback      bool
 	customUserAgent   string
 	seed              *int64
-	browserOption     string
-	osOption          string
-	deviceOption      string
+	mu                sync.Mutex
+	rng               *rand.Rand
+	browsers          []Browser
+	operatingSystems  []string
+	drawDesktopOS     bool
+	devices           []string
 	localeOption      []string
+	countryLocales    []string
+	country           string
+	referenceTime     time.Time
+	geolocation       bool
+	zones             *geo.Table
 	httpVersionOption string
 	strict            bool
 	mockWebRTC        bool
@@ -29,84 +54,244 @@ type Generator struct {
 	screenConstraints *ScreenConstraints
 	windowSize        *WindowSize
 	firefoxVersion    string
+
+	filters     []Filter
+	retryBudget int
+	stats       filterCounters
+
+	scoring lazyScoreIndex
 }
 
+// New returns a generator with the embedded data. The fingerprint network is
+// not embedded: GenerateHeadersOnly works without it, but Generate and Score
+// fail with an error wrapping ErrMissingDataFile until it is supplied with
+// WithDataDir and WithDataFallback. The network is trained by Apify's
+// fingerprint-suite, which publishes it as
+// packages/fingerprint-generator/src/data_files/fingerprint-network-definition.zip;
+// unzipped and saved as fingerprint-network.json it can be loaded as is.
 func New() (*Generator, error) {
-	net, err := bayesian.LoadFingerprintNetwork()
+	return NewWithOptions()
+}
+
+func (g *Generator) headerConstraints() headers.Constraints {
+	return headers.Constraints{
+		Browsers:         g.browsers,
+		OperatingSystems: g.operatingSystems,
+		Devices:          g.devices,
+		HTTPVersion:      g.httpVersionOption,
+		UserAgent:        g.customUserAgent,
+		Locales:          g.locales(),
+	}
+}
+
+// desktopOS are the systems WithCamoufoxConstraints draws from.
+var desktopOS = []string{"linux", "macos", "windows"}
+
+// sampleConstraints returns the constraints for generating one set of
+// headers, drawing the desktop system from rng for WithCamoufoxConstraints.
+func (g *Generator) sampleConstraints(rng *rand.Rand) headers.Constraints {
+	c := g.headerConstraints()
+	if g.drawDesktopOS && len(c.OperatingSystems) == 0 {
+		c.OperatingSystems = []string{desktopOS[rng.Intn(len(desktopOS))]}
+	}
+	return c
+}
+
+func (g *Generator) requestDependent() map[string]string {
+	reqDeps := make(map[string]string)
+	if g.customUserAgent != "" {
+		reqDeps["User-Agent"] = g.customUserAgent
+	}
+	return reqDeps
+}
+
+func (g *Generator) onlyBrowser(name string) bool {
+	return len(g.browsers) == 1 && g.browsers[0].Name == name
+}
+
+func (g *Generator) load() error {
+	zones, err := geo.Load(g.dataSource)
 	if err != nil {
-		return nil, fmt.Errorf("loading fingerprint network: %w", err)
+		return fmt.Errorf("loading country zones: %w", err)
 	}
-	hg, err := headers.NewHeaderGenerator()
+	if g.country != "" {
+		country, ok := zones.Country(g.country)
+		if !ok {
+			return fmt.Errorf("%w: unknown country %q", ErrInvalidConstraint, g.country)
+		}
+		g.countryLocales = country.Locales
+	}
+	net, err := bayesian.LoadFingerprintNetwork(g.dataSource)
+	switch {
+	case errors.Is(err, data.ErrMissingFile):
+		// The fingerprint network is not bundled. Headers can be generated
+		// without it, so only the calls that need it report it missing.
+		g.networkErr = fmt.Errorf("loading fingerprint network: %w", err)
+	case err != nil:
+		return fmt.Errorf("loading fingerprint network: %w", err)
+	}
+	hg, err := headers.NewHeaderGenerator(g.dataSource)
 	if err != nil {
-		return nil, fmt.Errorf("initializing header generator: %w", err)
+		return fmt.Errorf("initializing header generator: %w", err)
+	}
+	if err := hg.Validate(g.headerConstraints()); err != nil {
+		return err
 	}
-	return &Generator{
-		network: net,
-		headers: hg,
+	g.network = net
+	g.headers = hg
+	g.zones = zones
+	return nil
+}
 
-		seed: nil,
-	}, nil
+func newEntropyRand() *rand.Rand {
+	var b [8]byte
+	if _, err := cryptorand.Read(b[:]); err != nil {
+		return rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return rand.New(rand.NewSource(int64(binary.LittleEndian.Uint64(b[:]))))
 }
 
 func (g *Generator) SetFirefoxVersion(version string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
 	g.firefoxVersion = version
 }
 
-func (g *Generator) Generate() (*Fingerprint, error) {
+// callRand derives an independent source for a single generation so that
+// concurrent calls never share a *rand.Rand, while a seeded Generator still
+// produces a reproducible sequence when called sequentially.
+func (g *Generator) callRand() *rand.Rand {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return rand.New(rand.NewSource(g.rng.Int63()))
+}
 
-	if g.seed != nil {
-		rand.Seed(*g.seed)
-	} else {
-		rand.Seed(time.Now().UnixNano())
-	}
+func (g *Generator) currentFirefoxVersion() string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.firefoxVersion
+}
 
-	inputNet := make(map[string]string)
-	if g.browserOption != "" {
-		inputNet["*BROWSER"] = g.browserOption
+func (g *Generator) Generate() (*Fingerprint, error) {
+	return g.generate(g.callRand())
+}
+
+// generate samples fingerprints until one passes the filters or the retry
+// budget runs out. Sampling failures use up the budget as well.
+func (g *Generator) generate(rng *rand.Rand) (*Fingerprint, error) {
+	if g.networkErr != nil {
+		return nil, g.networkErr
+	}
+	budget := g.retryBudget
+	if budget == 0 {
+		budget = 1
+		if len(g.filters) > 0 {
+			budget = defaultRetryBudget
+		}
 	}
-	if g.osOption != "" {
-		inputNet["*OPERATING_SYSTEM"] = g.osOption
+	var lastErr error
+	for attempt := 0; attempt < budget; attempt++ {
+		fp, err := g.sample(rng)
+		if err != nil {
+			if !Retryable(err) {
+				return nil, err
+			}
+			lastErr = err
+			continue
+		}
+		if len(g.filters) == 0 {
+			return fp, nil
+		}
+		g.stats.attempts.Add(1)
+		if g.accept(fp) {
+			g.stats.accepted.Add(1)
+			return fp, nil
+		}
 	}
-	if g.deviceOption != "" {
-		inputNet["*DEVICE"] = g.deviceOption
+	if len(g.filters) == 0 {
+		return nil, lastErr
 	}
-	reqDeps := make(map[string]string)
-	if g.customUserAgent != "" {
-		reqDeps["User-Agent"] = g.customUserAgent
+	if lastErr != nil {
+		return nil, fmt.Errorf("%w: no fingerprint passed the filters in %d attempts (last error: %v)", ErrRetryBudgetExhausted, budget, lastErr)
 	}
+	return nil, fmt.Errorf("%w: no fingerprint passed the filters in %d attempts", ErrRetryBudgetExhausted, budget)
+}
 
-	hdrs, err := g.headers.GenerateWithConstraints(inputNet, reqDeps)
+// sample generates one fingerprint without applying the filters.
+func (g *Generator) sample(rng *rand.Rand) (*Fingerprint, error) {
+	hdrs, err := g.headers.GenerateWithConstraints(rng, g.sampleConstraints(rng), g.requestDependent())
 	if err != nil {
 		return nil, fmt.Errorf("generating headers: %w", err)
 	}
 
-	userAgent := hdrs["User-Agent"]
+	userAgent := hdrs.Get("User-Agent")
 	if userAgent == "" {
-		return nil, fmt.Errorf("generated headers missing User-Agent")
+		return nil, fmt.Errorf("%w: generated headers missing User-Agent", ErrSamplingFailed)
 	}
 
 	constraints := map[string]string{
 		"userAgent": userAgent,
 	}
 
-	sampleMap, err := g.network.GenerateSample(constraints)
+	if g.strict {
+		if err := g.network.CheckValues(constraints); err != nil {
+			return nil, fmt.Errorf("sampling fingerprint network: %w", err)
+		}
+	}
+	sampleMap, err := g.network.GenerateSample(rng, constraints)
 	if err != nil {
 		return nil, fmt.Errorf("sampling fingerprint network: %w", err)
 	}
+	if g.strict {
+		if err := checkSample(sampleMap); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSamplingFailed, err)
+		}
+	}
 
 	fp, err := transformFingerprint(sampleMap, hdrs, g.mockWebRTC, g.slim)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("%w: %w", ErrCorruptNetwork, err)
+	}
+
+	if locales := g.locales(); len(locales) > 0 {
+		applyLocales(fp, locales)
 	}
+	g.applyLocation(rng, fp)
 
-	if g.enableWhitelist || g.screenConstraints != nil || g.windowSize != nil || g.firefoxVersion != "" {
-		fp = g.applyCamoufoxConstraints(fp)
+	firefoxVersion := g.currentFirefoxVersion()
+	if g.enableWhitelist || g.screenConstraints != nil || g.windowSize != nil || firefoxVersion != "" {
+		fp = g.applyCamoufoxConstraints(rng, fp, firefoxVersion)
+	}
+	fp.deriveViews()
+	if g.strict {
+		if err := fp.checkRequired(); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrSamplingFailed, err)
+		}
 	}
 
 	return fp, nil
 }
 
-func (g *Generator) applyCamoufoxConstraints(fp *Fingerprint) *Fingerprint {
+// locales returns the locales given by WithLocales or, failing that, those of
+// the WithCountry country.
+func (g *Generator) locales() []string {
+	if len(g.localeOption) > 0 {
+		return g.localeOption
+	}
+	return g.countryLocales
+}
+
+// applyLocales makes the navigator and locale agree with locales, which the
+// header generator already used for Accept-Language.
+func applyLocales(fp *Fingerprint, locales []string) {
+	languages := append([]string(nil), locales...)
+	fp.Navigator.Language = languages[0]
+	fp.Navigator.Languages = languages
+	fp.Locale.Language = languages[0]
+	fp.Locale.Languages = languages
+}
+
+func (g *Generator) applyCamoufoxConstraints(rng *rand.Rand, fp *Fingerprint, firefoxVersion string) *Fingerprint {
 
 	filterFalsyValues(fp)
 
@@ -118,10 +303,10 @@ func (g *Generator) applyCamoufoxConstraints(fp *Fingerprint) *Fingerprint {
 		applyWindowSize(&fp.Screen, g.windowSize)
 	}
 
-	handleScreenPositioning(&fp.Screen)
+	handleScreenPositioning(rng, &fp.Screen)
 
-	if g.browserOption == "firefox" && g.firefoxVersion != "" {
-		updateFirefoxVersion(fp, g.firefoxVersion)
+	if g.onlyBrowser("firefox") && firefoxVersion != "" {
+		updateFirefoxVersion(fp, firefoxVersion)
 	} else if fp.Navigator.UserAgent != "" {
 
 		detectedVersion := extractFirefoxVersion(fp.Navigator.UserAgent)
@@ -137,51 +322,17 @@ func (g *Generator) applyCamoufoxConstraints(fp *Fingerprint) *Fingerprint {
 	return fp
 }
 
-func (g *Generator) GenerateHeadersOnly() (map[string]string, error) {
-
-	if g.seed != nil {
-		rand.Seed(*g.seed)
-	} else {
-		rand.Seed(time.Now().UnixNano())
-	}
+func (g *Generator) GenerateHeadersOnly() (Headers, error) {
 
-	inputNet := make(map[string]string)
-	if g.browserOption != "" {
-		inputNet["*BROWSER"] = g.browserOption
-	}
-	if g.osOption != "" {
-		inputNet["*OPERATING_SYSTEM"] = g.osOption
-	}
-	if g.deviceOption != "" {
-		inputNet["*DEVICE"] = g.deviceOption
-	}
-	reqDeps := make(map[string]string)
-	if g.customUserAgent != "" {
-		reqDeps["User-Agent"] = g.customUserAgent
-	}
-
-	headers, err := g.headers.GenerateWithConstraints(inputNet, reqDeps)
+	rng := g.callRand()
+	headers, err := g.headers.GenerateWithConstraints(rng, g.sampleConstraints(rng), g.requestDependent())
 	if err != nil {
 		return nil, err
 	}
 
 	if g.enableWhitelist {
-		filteredHeaders := make(map[string]string)
-		whitelist := DefaultWhitelist()
-
-		for _, allowed := range whitelist.Headers {
-			if value, exists := headers[allowed]; exists {
-				filteredHeaders[allowed] = value
-			}
-		}
-
-		if _, exists := filteredHeaders["User-Agent"]; !exists {
-			if value, exists := headers["User-Agent"]; exists {
-				filteredHeaders["User-Agent"] = value
-			}
-		}
-
-		return filteredHeaders, nil
+		allowed := append(DefaultWhitelist().Headers, "User-Agent")
+		return filterHeaders(headers, allowed), nil
 	}
 
 	return headers, nil
@@ -189,7 +340,7 @@ func (g *Generator) GenerateHeadersOnly() (map[string]string, error) {
 
 func transformFingerprint(
 	sample map[string]string,
-	headers map[string]string,
+	headers Headers,
 	mockWebRTC bool,
 	slim bool,
 ) (*Fingerprint, error) {
@@ -200,8 +351,6 @@ func transformFingerprint(
 		Slim:       slim,
 	}
 
-	fp.Canvas = CanvasFingerprint{}
-
 	if screenStr, ok := sample["screen"]; ok && screenStr != "" {
 		if len(screenStr) > len("*STRINGIFIED*") && screenStr[:len("*STRINGIFIED*")] == "*STRINGIFIED*" {
 			screenJSON := screenStr[len("*STRINGIFIED*"):]
@@ -231,17 +380,6 @@ func transformFingerprint(
 				ClientHeight:     getIntOrDefault(screenData, "clientHeight", 0),
 				HasHDR:           getBoolOrDefault(screenData, "hasHDR", false),
 			}
-
-			fp.Window = WindowFingerprint{
-				InnerHeight:      getIntOrDefault(screenData, "innerHeight", 0),
-				OuterHeight:      getIntOrDefault(screenData, "outerHeight", 0),
-				OuterWidth:       getIntOrDefault(screenData, "outerWidth", 0),
-				InnerWidth:       getIntOrDefault(screenData, "innerWidth", 0),
-				ScreenX:          getIntOrDefault(screenData, "screenX", 0),
-				PageXOffset:      getIntOrDefault(screenData, "pageXOffset", 0),
-				PageYOffset:      getIntOrDefault(screenData, "pageYOffset", 0),
-				DevicePixelRatio: getFloatOrDefault(screenData, "devicePixelRatio", 0),
-			}
 		}
 	}
 
@@ -388,11 +526,6 @@ func transformFingerprint(
 				Renderer: fmt.Sprintf("%v", videoCardData["renderer"]),
 				Vendor:   fmt.Sprintf("%v", videoCardData["vendor"]),
 			}
-
-			fp.WebGL = WebGLFingerprint{
-				Renderer: fmt.Sprintf("%v", videoCardData["renderer"]),
-				Vendor:   fmt.Sprintf("%v", videoCardData["vendor"]),
-			}
 		}
 	}
 
@@ -407,8 +540,13 @@ func transformFingerprint(
 				var mdMap map[string]interface{}
 				if err = json.Unmarshal([]byte(mdJSON), &mdMap); err == nil {
 
-					for _, v := range mdMap {
-						if strValue, ok := v.(string); ok {
+					keys := make([]string, 0, len(mdMap))
+					for k := range mdMap {
+						keys = append(keys, k)
+					}
+					sort.Strings(keys)
+					for _, k := range keys {
+						if strValue, ok := mdMap[k].(string); ok {
 							multimediaDevices = append(multimediaDevices, strValue)
 						}
 					}
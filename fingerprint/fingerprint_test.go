@@ -0,0 +1,137 @@
+package fingerprint
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/data"
+	"github.com/yourneighborhoodchef/browserforge/internal/fixtures"
+)
+
+func newTestGenerator(t testing.TB, opts ...Option) *Generator {
+	t.Helper()
+	g, err := NewWithOptions(append([]Option{WithDataSource(fixtures.Source)}, opts...)...)
+	if err != nil {
+		t.Fatalf("NewWithOptions: %v", err)
+	}
+	return g
+}
+
+func TestNewWithoutFingerprintNetwork(t *testing.T) {
+	g, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := g.GenerateHeadersOnly(); err != nil {
+		t.Errorf("GenerateHeadersOnly: %v", err)
+	}
+	if _, err := g.Generate(); !errors.Is(err, ErrMissingDataFile) {
+		t.Errorf("Generate error = %v, want ErrMissingDataFile", err)
+	}
+}
+
+func TestDataFallback(t *testing.T) {
+	raw, err := fixtures.Source.ReadFile(data.FingerprintNetworkFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{data.FingerprintNetworkFile: {Data: raw}}
+
+	_, err = NewWithOptions(WithDataFS(fsys))
+	if !errors.Is(err, ErrMissingDataFile) || !strings.Contains(err.Error(), data.CountryZonesFile) {
+		t.Errorf("NewWithOptions without the other files: error = %v, want ErrMissingDataFile for %s", err, data.CountryZonesFile)
+	}
+
+	g, err := NewWithOptions(WithDataFallback(), WithDataFS(fsys))
+	if err != nil {
+		t.Fatalf("NewWithOptions with WithDataFallback: %v", err)
+	}
+	if _, err := g.Generate(); err != nil {
+		t.Errorf("Generate: %v", err)
+	}
+}
+
+// checkPopulated fails unless fp has its screen, navigator, video card and
+// fonts filled in.
+func checkPopulated(t *testing.T, fp *Fingerprint) {
+	t.Helper()
+	if fp.Screen.Width == 0 || fp.Screen.Height == 0 {
+		t.Errorf("screen not filled: %+v", fp.Screen)
+	}
+	if fp.Navigator.UserAgent == "" || fp.Navigator.Platform == "" {
+		t.Errorf("navigator not filled: %+v", fp.Navigator)
+	}
+	if fp.Navigator.UserAgent != fp.Headers.Get("User-Agent") {
+		t.Errorf("navigator user agent %q differs from header %q", fp.Navigator.UserAgent, fp.Headers.Get("User-Agent"))
+	}
+	if fp.VideoCard == nil || fp.VideoCard.Renderer == "" || fp.VideoCard.Vendor == "" {
+		t.Errorf("video card not filled: %+v", fp.VideoCard)
+	}
+	if len(fp.Fonts) == 0 {
+		t.Error("no fonts")
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	g := newTestGenerator(t, WithSeed(1))
+	for i := 0; i < 20; i++ {
+		fp, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		checkPopulated(t, fp)
+	}
+}
+
+// TestGenerateTrainedNetwork runs against the trained fingerprint network,
+// which is not distributed with the module, in the directory named by
+// BROWSERFORGE_DATA_DIR.
+func TestGenerateTrainedNetwork(t *testing.T) {
+	dir := os.Getenv("BROWSERFORGE_DATA_DIR")
+	if dir == "" {
+		t.Skip("BROWSERFORGE_DATA_DIR is not set")
+	}
+	g, err := NewWithOptions(WithDataDir(dir), WithDataFallback(), WithSeed(1))
+	if err != nil {
+		t.Fatalf("NewWithOptions: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		fp, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		checkPopulated(t, fp)
+	}
+}
+
+// uncachedSource serves the embedded data without being data.Embedded, so
+// every generator built from it parses the networks again.
+type uncachedSource struct{ data.Source }
+
+// BenchmarkNew compares New with the parsed embedded data shared (warm)
+// against parsing it for every generator (cold).
+func BenchmarkNew(b *testing.B) {
+	b.Run("cold", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := NewWithOptions(WithDataSource(uncachedSource{data.Embedded})); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("warm", func(b *testing.B) {
+		if _, err := New(); err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := New(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,45 @@
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// TestSeededGolden locks in the output of a seeded generator: sampling must
+// not depend on map iteration order, the clock or the Go version.
+func TestSeededGolden(t *testing.T) {
+	g := newTestGenerator(t, WithSeed(42), WithCountry("US"), WithGeolocation())
+	var buf bytes.Buffer
+	for i := 0; i < 5; i++ {
+		fp, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		b, err := json.MarshalIndent(fp, "", "  ")
+		if err != nil {
+			t.Fatalf("MarshalIndent: %v", err)
+		}
+		buf.Write(b)
+		buf.WriteByte('\n')
+	}
+
+	path := filepath.Join("testdata", "seed42.golden")
+	if *update {
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run with -update to create it)", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("seeded output differs from %s; run with -update if the change is intended", path)
+	}
+}
@@ -0,0 +1,59 @@
+package fingerprint
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/geo"
+)
+
+// geolocationRadiusKm bounds how far generated coordinates stray from the
+// zone's reference city.
+const geolocationRadiusKm = 30
+
+// seededReferenceTime is the instant time zone offsets are computed for when
+// the generator is seeded and WithReferenceTime is not given, so that seeded
+// output does not depend on the clock.
+var seededReferenceTime = time.Date(2024, time.January, 15, 12, 0, 0, 0, time.UTC)
+
+// applyLocation fills the time zone, and optionally the coordinates, from
+// the configured country or, failing that, from navigator.languages.
+func (g *Generator) applyLocation(rng *rand.Rand, fp *Fingerprint) {
+	country, ok := g.zones.Country(g.country)
+	if !ok {
+		country, ok = g.zones.CountryFor(fp.Navigator.Languages)
+	}
+	if !ok {
+		return
+	}
+	zone := country.SampleZone(rng)
+	fp.Locale.Country = country.Code
+	fp.Locale.TimeZone = zone.Name
+	fp.Locale.TimezoneOffset = geo.TimezoneOffset(zone.Location(), g.now())
+
+	if g.geolocation {
+		lat, lon := geo.Scatter(rng, zone.Latitude, zone.Longitude, geolocationRadiusKm)
+		fp.Geolocation = &Geolocation{
+			Latitude:  roundTo(lat, 4),
+			Longitude: roundTo(lon, 4),
+			Accuracy:  float64(20 + rng.Intn(80)),
+		}
+	}
+}
+
+// now returns the instant time zone offsets are computed for.
+func (g *Generator) now() time.Time {
+	switch {
+	case !g.referenceTime.IsZero():
+		return g.referenceTime
+	case g.seed != nil:
+		return seededReferenceTime
+	}
+	return time.Now()
+}
+
+func roundTo(v float64, places int) float64 {
+	p := math.Pow(10, float64(places))
+	return math.Round(v*p) / p
+}
@@ -0,0 +1,53 @@
+package fingerprint
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestReferenceTime(t *testing.T) {
+	winter := time.Date(2025, time.January, 10, 0, 0, 0, 0, time.UTC)
+	summer := time.Date(2025, time.July, 10, 0, 0, 0, 0, time.UTC)
+	for _, tc := range []struct {
+		name string
+		opts []Option
+		want int
+	}{
+		{"seeded", []Option{WithSeed(1)}, -60},
+		{"winter", []Option{WithReferenceTime(winter)}, -60},
+		{"summer", []Option{WithSeed(1), WithReferenceTime(summer)}, -120},
+	} {
+		g := newTestGenerator(t, append(tc.opts, WithCountry("DE"))...)
+		fp, err := g.Generate()
+		if err != nil {
+			t.Fatalf("%s: Generate: %v", tc.name, err)
+		}
+		if fp.Locale.TimeZone != "Europe/Berlin" || fp.Locale.TimezoneOffset != tc.want {
+			t.Errorf("%s: zone %s offset %d, want Europe/Berlin %d", tc.name, fp.Locale.TimeZone, fp.Locale.TimezoneOffset, tc.want)
+		}
+	}
+}
+
+func TestCountryLocalesScored(t *testing.T) {
+	g := newTestGenerator(t, WithSeed(1), WithCountry("DE"))
+	if len(g.localeOption) != 0 {
+		t.Errorf("WithCountry set the locale option to %v", g.localeOption)
+	}
+	fp, err := g.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	if got := fp.Navigator.Languages; len(got) != 1 || got[0] != "de-DE" {
+		t.Fatalf("languages = %v, want [de-DE]", got)
+	}
+	score, err := g.Score(fp)
+	if err != nil || math.IsInf(score, -1) {
+		t.Errorf("Score of own fingerprint = %v, %v", score, err)
+	}
+
+	fp.Navigator.Languages = []string{"xx-XX"}
+	if score, err := g.Score(fp); err != nil || !math.IsInf(score, -1) {
+		t.Errorf("Score with foreign languages = %v, %v; want -Inf", score, err)
+	}
+}
@@ -2,11 +2,23 @@ package fingerprint
 
 import (
 	"fmt"
+	"io/fs"
 	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/data"
+	"github.com/yourneighborhoodchef/browserforge/internal/headers"
 )
 
 type Option func(*Generator) error
 
+// WithCustomUserAgent sends userAgent instead of a sampled one. When the
+// networks know it, the browser, operating system, other headers and
+// fingerprint are sampled given it. Other user agents, including ones the
+// other constraints rule out, only replace the sampled User-Agent: the rest
+// is sampled as without the option, from the fingerprint network's fallback
+// branches, and Score returns -Inf. WithStrict turns those into errors.
 func WithCustomUserAgent(userAgent string) Option {
 	return func(g *Generator) error {
 		g.customUserAgent = userAgent
@@ -14,48 +26,200 @@ func WithCustomUserAgent(userAgent string) Option {
 	}
 }
 
+// WithSeed makes generation reproducible: generators with the same seed and
+// options return the same fingerprints in the same order.
 func WithSeed(seed int64) Option {
 	return func(g *Generator) error {
 		g.seed = &seed
+		g.rng = rand.New(rand.NewSource(seed))
 		return nil
 	}
 }
 
 func WithDeviceCategory(category string) Option {
+	return WithDeviceCategories(category)
+}
+
+func WithBrowser(browser string) Option {
+	return WithBrowsers(browser)
+}
+
+func WithOperatingSystem(os string) Option {
+	return WithOperatingSystems(os)
+}
+
+// WithBrowsers restricts generation to the given browsers. The sampled
+// browser keeps the proportions the network assigns among the permitted set.
+func WithBrowsers(browsers ...string) Option {
+	specs := make([]Browser, len(browsers))
+	for i, name := range browsers {
+		specs[i] = Browser{Name: name}
+	}
+	return WithBrowserSpecs(specs...)
+}
+
+// WithBrowserSpecs restricts generation to browsers matching any of specs,
+// e.g. Browser{Name: "chrome", MinVersion: 128}. Generator construction
+// fails when no known browser satisfies a spec.
+func WithBrowserSpecs(specs ...Browser) Option {
 	return func(g *Generator) error {
-		g.deviceOption = category
+		if len(specs) == 0 {
+			return fmt.Errorf("invalid browsers: at least one is required")
+		}
+		for _, b := range specs {
+			if b.Name == "" {
+				return fmt.Errorf("invalid browser: name is required")
+			}
+			if b.MaxVersion > 0 && b.MinVersion > b.MaxVersion {
+				return fmt.Errorf("invalid browser %s: minimum version exceeds maximum", b.Name)
+			}
+		}
+		g.browsers = specs
 		return nil
 	}
 }
 
-func WithBrowser(browser string) Option {
+func WithOperatingSystems(systems ...string) Option {
 	return func(g *Generator) error {
-		g.browserOption = browser
+		if len(systems) == 0 {
+			return fmt.Errorf("invalid operating systems: at least one is required")
+		}
+		g.operatingSystems = systems
 		return nil
 	}
 }
 
-func WithOperatingSystem(os string) Option {
+func WithDeviceCategories(categories ...string) Option {
 	return func(g *Generator) error {
-		g.osOption = os
+		if len(categories) == 0 {
+			return fmt.Errorf("invalid device categories: at least one is required")
+		}
+		g.devices = categories
 		return nil
 	}
 }
 
-func WithCamoufoxConstraints() Option {
+// WithHTTPVersion pins the HTTP version the headers are generated for, "1"
+// or "2". HTTP/2 headers keep their lowercase field names.
+func WithHTTPVersion(version string) Option {
+	return func(g *Generator) error {
+		if version != "1" && version != "2" {
+			return fmt.Errorf("invalid HTTP version %q: must be \"1\" or \"2\"", version)
+		}
+		g.httpVersionOption = version
+		return nil
+	}
+}
+
+// WithLocales sets the user's preferred languages, most preferred first.
+// They replace navigator.language(s) and the Accept-Language header, which
+// is weighted the way the sampled browser weights it. Safari and browsers on
+// iOS only ever send one locale, so several locales rule them out, and
+// constraints that leave no other browser are rejected with
+// ErrConstraintUnsatisfiable. Malformed or repeated tags are rejected.
+func WithLocales(locales ...string) Option {
 	return func(g *Generator) error {
+		if len(locales) == 0 {
+			return fmt.Errorf("invalid locales: at least one is required")
+		}
+		canonical := make([]string, len(locales))
+		for i, tag := range locales {
+			c, err := headers.CanonicalLocale(tag)
+			if err != nil {
+				return err
+			}
+			canonical[i] = c
+		}
+		if err := headers.ValidateLocales(canonical); err != nil {
+			return err
+		}
+		g.localeOption = canonical
+		return nil
+	}
+}
 
-		g.browserOption = "firefox"
+// WithCountry places the fingerprint in the country with the given ISO 3166
+// code. The time zone is drawn from the country's zones and, unless
+// WithLocales is also given, the locales default to the country's.
+func WithCountry(code string) Option {
+	return func(g *Generator) error {
+		if len(code) != 2 {
+			return fmt.Errorf("invalid country %q: must be a two-letter code", code)
+		}
+		g.country = strings.ToUpper(code)
+		return nil
+	}
+}
+
+// WithReferenceTime sets the instant at which the time zone offset is taken,
+// which decides whether daylight saving time applies. By default it is the
+// time of generation, or a fixed date in January for seeded generators.
+func WithReferenceTime(t time.Time) Option {
+	return func(g *Generator) error {
+		if t.IsZero() {
+			return fmt.Errorf("invalid reference time: zero")
+		}
+		g.referenceTime = t
+		return nil
+	}
+}
+
+// WithGeolocation adds coordinates near a city in the fingerprint's time zone.
+func WithGeolocation() Option {
+	return func(g *Generator) error {
+		g.geolocation = true
+		return nil
+	}
+}
 
-		desktopOS := []string{"linux", "macos", "windows"}
-		if g.seed != nil {
+// WithStrict makes Generate return an error instead of a partial
+// fingerprint: a *ConstraintError when the user agent is one the fingerprint
+// network does not know, which happens with WithCustomUserAgent or when it
+// was trained apart from the header network, and a *FieldError when a required field is missing or a
+// sampled value cannot be parsed. Without it such fields are left empty.
+func WithStrict() Option {
+	return func(g *Generator) error {
+		g.strict = true
+		return nil
+	}
+}
 
-			r := rand.New(rand.NewSource(*g.seed))
-			g.osOption = desktopOS[r.Intn(len(desktopOS))]
-		} else {
+// WithFilter keeps only fingerprints for which filter returns true, sampling
+// again until one passes or the retry budget runs out. Filters from repeated
+// calls must all pass. Filters should accept a reasonable share of samples:
+// FilterStats shows how many do.
+func WithFilter(filter Filter) Option {
+	return func(g *Generator) error {
+		if filter == nil {
+			return fmt.Errorf("invalid filter: nil")
+		}
+		g.filters = append(g.filters, filter)
+		return nil
+	}
+}
 
-			g.osOption = desktopOS[rand.Intn(len(desktopOS))]
+// WithRetryBudget sets how many fingerprints Generate samples before giving
+// up. It defaults to 100 with filters and to 1 without.
+func WithRetryBudget(attempts int) Option {
+	return func(g *Generator) error {
+		if attempts <= 0 {
+			return fmt.Errorf("invalid retry budget %d: must be positive", attempts)
 		}
+		g.retryBudget = attempts
+		return nil
+	}
+}
+
+// WithCamoufoxConstraints generates desktop Firefox fingerprints with only
+// the properties Camoufox takes. Unless an operating system is set by a later
+// option, each fingerprint is for Linux, macOS or Windows, drawn with equal
+// chance.
+func WithCamoufoxConstraints() Option {
+	return func(g *Generator) error {
+
+		g.browsers = []Browser{{Name: "firefox"}}
+		g.operatingSystems = nil
+		g.drawDesktopOS = true
 
 		g.enableWhitelist = true
 
@@ -89,11 +253,57 @@ func WithWindowSize(width, height int) Option {
 	}
 }
 
+func WithDataSource(src DataSource) Option {
+	return func(g *Generator) error {
+		if src == nil {
+			return fmt.Errorf("invalid data source: nil")
+		}
+		g.dataSource = src
+		return nil
+	}
+}
+
+// WithDataFS loads the model files from fsys. Files missing from fsys are
+// reported as missing unless WithDataFallback is given.
+func WithDataFS(fsys fs.FS) Option {
+	return func(g *Generator) error {
+		if fsys == nil {
+			return fmt.Errorf("invalid data filesystem: nil")
+		}
+		g.dataSource = data.FromFS(fsys)
+		return nil
+	}
+}
+
+// WithDataDir loads the model files from the directory at path, like
+// WithDataFS.
+func WithDataDir(path string) Option {
+	return func(g *Generator) error {
+		src, err := data.FromDir(path)
+		if err != nil {
+			return fmt.Errorf("invalid data directory: %w", err)
+		}
+		g.dataSource = src
+		return nil
+	}
+}
+
+// WithDataFallback takes the model files the data source does not provide
+// from the embedded defaults, so that a directory may hold only the
+// fingerprint network or override only some of the files. Without it, a
+// missing file fails with an error wrapping ErrMissingDataFile.
+func WithDataFallback() Option {
+	return func(g *Generator) error {
+		g.dataFallback = true
+		return nil
+	}
+}
+
 func NewWithOptions(opts ...Option) (*Generator, error) {
 
-	g, err := New()
-	if err != nil {
-		return nil, err
+	g := &Generator{
+		dataSource: data.Embedded,
+		rng:        newEntropyRand(),
 	}
 
 	for _, opt := range opts {
@@ -101,6 +311,13 @@ func NewWithOptions(opts ...Option) (*Generator, error) {
 			return nil, err
 		}
 	}
+	if g.dataFallback && g.dataSource != data.Embedded {
+		g.dataSource = data.Overlay(g.dataSource, data.Embedded)
+	}
+
+	if err := g.load(); err != nil {
+		return nil, err
+	}
 
 	return g, nil
 }
@@ -0,0 +1,125 @@
+package fingerprint
+
+import (
+	"errors"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/fixtures"
+)
+
+func TestCustomUserAgent(t *testing.T) {
+	const ua = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
+	g := newTestGenerator(t, WithSeed(7), WithCustomUserAgent(ua))
+	for i := 0; i < 200; i++ {
+		fp, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		if fp.Navigator.UserAgent != ua || fp.Headers.Get("User-Agent") != ua {
+			t.Fatalf("user agent = %q, header %q", fp.Navigator.UserAgent, fp.Headers.Get("User-Agent"))
+		}
+		for _, f := range Validate(fp) {
+			if f.Severity == SeverityError {
+				t.Errorf("sample %d: %v", i, f)
+			}
+		}
+		score, err := g.Score(fp)
+		if err != nil {
+			t.Fatalf("Score: %v", err)
+		}
+		if math.IsInf(score, -1) {
+			t.Errorf("sample %d scores -Inf: %v", i, fp.Headers)
+		}
+	}
+}
+
+func TestCustomUserAgentUnknown(t *testing.T) {
+	for name, tt := range map[string]struct {
+		ua   string
+		opts []Option
+		// known is set for user agents the fingerprint network knows, which
+		// WithStrict accepts.
+		known bool
+	}{
+		"newer version": {ua: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/140.0.0.0 Safari/537.36"},
+		"unknown":       {ua: "Mozilla/5.0 (X11; Linux x86_64) NotABrowser/1.0"},
+		"excluded": {
+			ua:    "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36",
+			opts:  []Option{WithBrowser("firefox")},
+			known: true,
+		},
+	} {
+		g := newTestGenerator(t, append(tt.opts, WithSeed(3), WithCustomUserAgent(tt.ua))...)
+		for i := 0; i < 20; i++ {
+			fp, err := g.Generate()
+			if err != nil {
+				t.Fatalf("%s: Generate: %v", name, err)
+			}
+			if fp.Navigator.UserAgent != tt.ua || fp.Headers.Get("User-Agent") != tt.ua {
+				t.Fatalf("%s: user agent = %q, header %q", name, fp.Navigator.UserAgent, fp.Headers.Get("User-Agent"))
+			}
+			if _, ok := fp.Headers.Lookup("sec-ch-ua"); ok && name == "excluded" {
+				t.Errorf("%s: sample %d has Chrome's client hints, want Firefox headers", name, i)
+			}
+			score, err := g.Score(fp)
+			if err != nil {
+				t.Fatalf("%s: Score: %v", name, err)
+			}
+			if !math.IsInf(score, -1) {
+				t.Errorf("%s: sample %d scores %v, want -Inf", name, i, score)
+			}
+		}
+
+		g = newTestGenerator(t, append(tt.opts, WithSeed(3), WithCustomUserAgent(tt.ua), WithStrict())...)
+		_, err := g.Generate()
+		var constraintErr *ConstraintError
+		if !tt.known && (!errors.As(err, &constraintErr) || constraintErr.Value != tt.ua) {
+			t.Errorf("%s: strict Generate error = %v, want a *ConstraintError for the user agent", name, err)
+		}
+	}
+}
+
+func TestSeveralLocalesRejectSafari(t *testing.T) {
+	_, err := NewWithOptions(WithDataSource(fixtures.Source), WithBrowser("safari"), WithLocales("en-US", "de-DE"))
+	if !errors.Is(err, ErrConstraintUnsatisfiable) {
+		t.Errorf("NewWithOptions error = %v, want ErrConstraintUnsatisfiable", err)
+	}
+	newTestGenerator(t, WithBrowser("safari"), WithLocales("en-US"))
+}
+
+func TestCamoufoxDrawsSystemPerFingerprint(t *testing.T) {
+	generate := func(opts ...Option) []string {
+		g := newTestGenerator(t, opts...)
+		var systems []string
+		for i := 0; i < 30; i++ {
+			hdrs, err := g.GenerateHeadersOnly()
+			if err != nil {
+				t.Fatalf("GenerateHeadersOnly: %v", err)
+			}
+			systems = append(systems, osFamily(hdrs.Get("User-Agent")))
+		}
+		return systems
+	}
+
+	// The draw happens while generating, so the options' order does not
+	// matter and every fingerprint gets its own system.
+	systems := generate(WithCamoufoxConstraints(), WithSeed(5))
+	if again := generate(WithSeed(5), WithCamoufoxConstraints()); strings.Join(again, " ") != strings.Join(systems, " ") {
+		t.Errorf("systems depend on the order of the options:\n%v\n%v", systems, again)
+	}
+	seen := map[string]bool{}
+	for _, os := range systems {
+		seen[os] = true
+	}
+	if len(seen) != len(desktopOS) {
+		t.Errorf("systems drawn: %v, want all of %v", systems, desktopOS)
+	}
+
+	for _, os := range generate(WithCamoufoxConstraints(), WithOperatingSystem("macos")) {
+		if os != "macos" {
+			t.Fatalf("WithOperatingSystem after WithCamoufoxConstraints gave %s", os)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// SchemaVersion is the version of the JSON format fingerprints are written
+// in. Every document carries it in a top-level "schemaVersion" field;
+// documents without one were written before the format was versioned and
+// are read as version 0.
+//
+// In version 1 "window", "webgl" and "canvas" always agree with "screen" and
+// "videoCard"; upgrading a version 0 document derives them from those.
+const SchemaVersion = 1
+
+// Migration upgrades the top-level fields of a fingerprint document by one
+// schema version. Values are left encoded so that header order survives.
+type Migration func(fields map[string]json.RawMessage) error
+
+var migrations = struct {
+	sync.RWMutex
+	steps map[int]Migration
+}{steps: map[int]Migration{
+	0: migrateV0,
+}}
+
+// RegisterMigration adds the upgrade from schema version from to from+1.
+// It panics if that step is already registered.
+func RegisterMigration(from int, m Migration) {
+	migrations.Lock()
+	defer migrations.Unlock()
+	if _, dup := migrations.steps[from]; dup {
+		panic(fmt.Sprintf("fingerprint: migration from schema version %d registered twice", from))
+	}
+	migrations.steps[from] = m
+}
+
+func migrateV0(fields map[string]json.RawMessage) error {
+	var fp Fingerprint
+	if raw, ok := fields["screen"]; ok {
+		if err := json.Unmarshal(raw, &fp.Screen); err != nil {
+			return fmt.Errorf("invalid screen: %w", err)
+		}
+	}
+	if raw, ok := fields["videoCard"]; ok {
+		if err := json.Unmarshal(raw, &fp.VideoCard); err != nil {
+			return fmt.Errorf("invalid videoCard: %w", err)
+		}
+	}
+	fp.deriveViews()
+	for name, v := range map[string]interface{}{"window": fp.Window, "webgl": fp.WebGL, "canvas": fp.Canvas} {
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		fields[name] = raw
+	}
+	return nil
+}
+
+// Marshal encodes fp in the current schema version. It is equivalent to
+// json.Marshal(fp).
+func Marshal(fp *Fingerprint) ([]byte, error) {
+	return json.Marshal(fp)
+}
+
+// Unmarshal decodes a fingerprint written by Marshal in this or an earlier
+// schema version and checks that it is usable.
+func Unmarshal(data []byte) (*Fingerprint, error) {
+	fp := new(Fingerprint)
+	if err := json.Unmarshal(data, fp); err != nil {
+		return nil, err
+	}
+	if err := fp.checkRequired(); err != nil {
+		return nil, err
+	}
+	return fp, nil
+}
+
+// plainFingerprint has Fingerprint's fields without its JSON methods.
+type plainFingerprint Fingerprint
+
+func (fp Fingerprint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		SchemaVersion int `json:"schemaVersion"`
+		*plainFingerprint
+	}{SchemaVersion, (*plainFingerprint)(&fp)})
+}
+
+func (fp *Fingerprint) UnmarshalJSON(data []byte) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fmt.Errorf("decoding fingerprint: %w", err)
+	}
+	if fields == nil {
+		// A JSON null leaves fp unchanged, as it does for other types.
+		return nil
+	}
+	version := 0
+	if raw, ok := fields["schemaVersion"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return fmt.Errorf("decoding fingerprint: invalid schema version: %w", err)
+		}
+		delete(fields, "schemaVersion")
+	}
+	if version > SchemaVersion {
+		return fmt.Errorf("decoding fingerprint: schema version %d is newer than supported version %d", version, SchemaVersion)
+	}
+	for v := version; v < SchemaVersion; v++ {
+		migrations.RLock()
+		m := migrations.steps[v]
+		migrations.RUnlock()
+		if m == nil {
+			return fmt.Errorf("decoding fingerprint: no migration from schema version %d", v)
+		}
+		if err := m(fields); err != nil {
+			return fmt.Errorf("decoding fingerprint: migrating from schema version %d: %w", v, err)
+		}
+	}
+
+	migrated, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	var plain plainFingerprint
+	if err := json.Unmarshal(migrated, &plain); err != nil {
+		return fmt.Errorf("decoding fingerprint: %w", err)
+	}
+	*fp = Fingerprint(plain)
+	return nil
+}
+
+// checkRequired reports fields without which a fingerprint cannot be used.
+func (fp *Fingerprint) checkRequired() error {
+	if fp.Navigator.UserAgent == "" {
+		return &FieldError{Field: "navigator.userAgent"}
+	}
+	if fp.Screen.Width <= 0 || fp.Screen.Height <= 0 {
+		return &FieldError{Field: "screen"}
+	}
+	return nil
+}
+
+// deriveViews fills the fields that repeat other parts of the fingerprint.
+func (fp *Fingerprint) deriveViews() {
+	fp.Window = WindowFingerprint{
+		InnerHeight:      fp.Screen.InnerHeight,
+		OuterHeight:      fp.Screen.OuterHeight,
+		OuterWidth:       fp.Screen.OuterWidth,
+		InnerWidth:       fp.Screen.InnerWidth,
+		ScreenX:          fp.Screen.ScreenX,
+		PageXOffset:      fp.Screen.PageXOffset,
+		PageYOffset:      fp.Screen.PageYOffset,
+		DevicePixelRatio: fp.Screen.DevicePixelRatio,
+	}
+	fp.WebGL = WebGLFingerprint{}
+	if fp.VideoCard != nil {
+		fp.WebGL = WebGLFingerprint{
+			Renderer: fp.VideoCard.Renderer,
+			Vendor:   fp.VideoCard.Vendor,
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMarshalRoundTrip(t *testing.T) {
+	g := newTestGenerator(t, WithSeed(3))
+	fp, err := g.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	raw, err := Marshal(fp)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"schemaVersion", "window", "webgl", "canvas"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("document lacks %q", name)
+		}
+	}
+	restored, err := Unmarshal(raw)
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if !reflect.DeepEqual(restored, fp) {
+		t.Errorf("round trip changed the fingerprint:\ngot  %+v\nwant %+v", restored, fp)
+	}
+}
+
+func TestUnmarshalMigratesVersion0(t *testing.T) {
+	doc := `{"navigator":{"userAgent":"Mozilla/5.0"},
+		"screen":{"width":1920,"height":1080,"innerWidth":1280,"outerWidth":1300},
+		"videoCard":{"renderer":"ANGLE","vendor":"Google Inc."}}`
+	fp, err := Unmarshal([]byte(doc))
+	if err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if fp.Window.InnerWidth != 1280 || fp.Window.OuterWidth != 1300 {
+		t.Errorf("window = %+v, want it derived from the screen", fp.Window)
+	}
+	if fp.WebGL.Renderer != "ANGLE" || fp.WebGL.Vendor != "Google Inc." {
+		t.Errorf("webgl = %+v, want it derived from the video card", fp.WebGL)
+	}
+}
+
+func TestUnmarshalRejectsNewerVersion(t *testing.T) {
+	if _, err := Unmarshal([]byte(`{"schemaVersion":99}`)); err == nil {
+		t.Error("Unmarshal accepted a newer schema version")
+	}
+}
+
+func TestPartialDecoding(t *testing.T) {
+	var fp Fingerprint
+	if err := json.Unmarshal([]byte(`{"schemaVersion":1,"fonts":["Arial"]}`), &fp); err != nil {
+		t.Fatalf("json.Unmarshal of a partial document: %v", err)
+	}
+	if len(fp.Fonts) != 1 {
+		t.Errorf("fonts = %v", fp.Fonts)
+	}
+
+	_, err := Unmarshal([]byte(`{"schemaVersion":1,"fonts":["Arial"]}`))
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) || fieldErr.Field != "navigator.userAgent" {
+		t.Errorf("Unmarshal error = %v, want a missing navigator.userAgent", err)
+	}
+}
+
+func TestUnmarshalNull(t *testing.T) {
+	fp := Fingerprint{Fonts: []string{"Arial"}}
+	if err := json.Unmarshal([]byte("null"), &fp); err != nil {
+		t.Fatalf("json.Unmarshal(null): %v", err)
+	}
+	if len(fp.Fonts) != 1 {
+		t.Errorf("null changed the fingerprint: fonts = %v", fp.Fonts)
+	}
+
+	_, err := Unmarshal([]byte("null"))
+	var fieldErr *FieldError
+	if !errors.As(err, &fieldErr) {
+		t.Errorf("Unmarshal(null) error = %v, want a FieldError", err)
+	}
+}
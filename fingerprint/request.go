@@ -0,0 +1,314 @@
+package fingerprint
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/headers"
+)
+
+// Destination is the fetch destination of a request, as sent in
+// Sec-Fetch-Dest.
+type Destination string
+
+const (
+	DestinationDocument Destination = "document"
+	DestinationIframe   Destination = "iframe"
+	DestinationImage    Destination = "image"
+	DestinationScript   Destination = "script"
+	DestinationStyle    Destination = "style"
+	DestinationFont     Destination = "font"
+	// DestinationEmpty is used by fetch() and XMLHttpRequest.
+	DestinationEmpty Destination = "empty"
+)
+
+// Values of Sec-Fetch-Site.
+const (
+	SiteNone       = "none"
+	SiteSameOrigin = "same-origin"
+	SiteSameSite   = "same-site"
+	SiteCrossSite  = "cross-site"
+)
+
+// RequestContext describes a single request made by the browser a
+// fingerprint belongs to.
+type RequestContext struct {
+	// Method defaults to GET.
+	Method string
+	URL    string
+	// Referer is the URL of the page that made the request, if any.
+	Referer string
+	// Destination defaults to DestinationDocument.
+	Destination Destination
+	// Site overrides the Sec-Fetch-Site value derived from URL and
+	// Referer, for requests whose initiator does not send a referer.
+	Site string
+	// UserInitiated marks navigations triggered by a click or key press.
+	UserInitiated bool
+}
+
+func (rc RequestContext) method() string {
+	if rc.Method == "" {
+		return "GET"
+	}
+	return strings.ToUpper(rc.Method)
+}
+
+func (rc RequestContext) destination() Destination {
+	if rc.Destination == "" {
+		return DestinationDocument
+	}
+	return rc.Destination
+}
+
+func (d Destination) mode() (string, bool) {
+	switch d {
+	case DestinationDocument, DestinationIframe:
+		return "navigate", true
+	case DestinationImage, DestinationScript, DestinationStyle:
+		return "no-cors", true
+	case DestinationFont, DestinationEmpty:
+		return "cors", true
+	}
+	return "", false
+}
+
+// subresourceAccept holds the Accept header browsers send per destination.
+// Destinations missing for a browser send */*.
+var subresourceAccept = map[string]map[Destination]string{
+	"chrome": {
+		DestinationImage: "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8",
+		DestinationStyle: "text/css,*/*;q=0.1",
+	},
+	"firefox": {
+		DestinationImage: "image/avif,image/webp,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5",
+		DestinationStyle: "text/css,*/*;q=0.1",
+		DestinationFont:  "application/font-woff2;q=1.0,application/font-woff;q=0.9,*/*;q=0.8",
+	},
+	"safari": {
+		DestinationImage: "image/webp,image/avif,image/jxl,image/heic,image/heic-sequence,video/*;q=0.8,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5",
+		DestinationStyle: "text/css,*/*;q=0.1",
+	},
+}
+
+const documentAccept = "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8"
+
+// HeadersFor returns the headers fp's browser sends for the request described
+// by rc: the fingerprint's headers with Accept, Referer, Origin,
+// Upgrade-Insecure-Requests, the Sec-Fetch-* headers and any HTTP/2
+// pseudo-headers adjusted to the request, in the browser's order. Accept-Language is derived from
+// navigator.languages when the fingerprint has none.
+func (g *Generator) HeadersFor(fp *Fingerprint, rc RequestContext) (Headers, error) {
+	target, err := url.Parse(rc.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid request URL: %w", err)
+	}
+	if !target.IsAbs() {
+		return nil, fmt.Errorf("invalid request URL %q: must be absolute", rc.URL)
+	}
+	var referer *url.URL
+	if rc.Referer != "" {
+		if referer, err = url.Parse(rc.Referer); err != nil {
+			return nil, fmt.Errorf("invalid referer: %w", err)
+		}
+	}
+	dest := rc.destination()
+	mode, ok := dest.mode()
+	if !ok {
+		return nil, fmt.Errorf("unknown fetch destination %q", dest)
+	}
+	site := rc.Site
+	if site == "" {
+		switch {
+		case referer != nil:
+			site = fetchSite(target, referer)
+		case mode == "navigate":
+			site = SiteNone
+		default:
+			return nil, fmt.Errorf("%s request needs a referer or site", dest)
+		}
+	}
+
+	browser := browserFamily(fp.Navigator.UserAgent)
+	hdrs := fp.Headers.Clone()
+	set := func(name, value string) {
+		setHeader(&hdrs, name, value)
+	}
+
+	if mode == "navigate" {
+		if _, ok := hdrs.Lookup("Accept"); !ok {
+			set("Accept", documentAccept)
+		}
+		set("Upgrade-Insecure-Requests", "1")
+	} else {
+		accept, ok := subresourceAccept[browser][dest]
+		if !ok {
+			accept = "*/*"
+		}
+		set("Accept", accept)
+		hdrs.Del("Upgrade-Insecure-Requests")
+	}
+
+	if _, ok := hdrs.Lookup("Accept-Language"); !ok && len(fp.Navigator.Languages) > 0 {
+		set("Accept-Language", headers.AcceptLanguage(browser, fp.Navigator.Languages))
+	}
+
+	hdrs.Del("Referer")
+	if ref := refererFor(target, referer); ref != "" {
+		set("Referer", ref)
+	}
+	hdrs.Del("Origin")
+	method := rc.method()
+	if (method != "GET" && method != "HEAD") || (mode == "cors" && site != SiteSameOrigin) {
+		origin := "null"
+		if referer != nil {
+			origin = originOf(referer)
+		}
+		set("Origin", origin)
+	}
+
+	if _, ok := hdrs.Lookup(":method"); ok {
+		set(":method", method)
+		set(":authority", target.Host)
+		set(":scheme", target.Scheme)
+		set(":path", target.RequestURI())
+	}
+
+	for _, name := range []string{"Sec-Fetch-Site", "Sec-Fetch-Mode", "Sec-Fetch-User", "Sec-Fetch-Dest"} {
+		hdrs.Del(name)
+	}
+	if isTrustworthy(target) {
+		if sendsFetchMetadata(browser, fp.Navigator.UserAgent) {
+			set("Sec-Fetch-Site", site)
+			set("Sec-Fetch-Mode", mode)
+			if mode == "navigate" && rc.UserInitiated && browser != "safari" {
+				set("Sec-Fetch-User", "?1")
+			}
+			set("Sec-Fetch-Dest", string(dest))
+		}
+	} else {
+		// Client hints and fetch metadata are only sent to secure origins.
+		for _, hdr := range fp.Headers {
+			if strings.HasPrefix(strings.ToLower(hdr.Name), "sec-ch-") {
+				hdrs.Del(hdr.Name)
+			}
+		}
+	}
+
+	return g.headers.Order(hdrs, browser), nil
+}
+
+// Order sorts hdrs into the order fp's browser sends them in.
+func (g *Generator) Order(fp *Fingerprint, hdrs Headers) Headers {
+	return g.headers.Order(hdrs, browserFamily(fp.Navigator.UserAgent))
+}
+
+// setHeader sets a header, spelling a new name in lowercase when hdrs are
+// HTTP/2 headers.
+func setHeader(hdrs *Headers, name, value string) {
+	for _, hdr := range *hdrs {
+		if hdr.Name == "user-agent" {
+			name = strings.ToLower(name)
+			break
+		}
+	}
+	hdrs.Set(name, value)
+}
+
+// browserFamily names the browser in a User-Agent the way headers-order.json
+// does.
+func browserFamily(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "Firefox/") || strings.Contains(userAgent, "FxiOS/"):
+		return "firefox"
+	case strings.Contains(userAgent, "Edg/") || strings.Contains(userAgent, "EdgA/") || strings.Contains(userAgent, "EdgiOS/"):
+		return "edge"
+	case strings.Contains(userAgent, "Chrome/") || strings.Contains(userAgent, "CriOS/"):
+		return "chrome"
+	case strings.Contains(userAgent, "Safari/"):
+		return "safari"
+	}
+	return ""
+}
+
+var safariVersionRe = regexp.MustCompile(`Version/(\d+)\.(\d+)`)
+
+// sendsFetchMetadata reports whether the browser sends Sec-Fetch-* headers.
+// Safari added them in 16.4; the other browsers have sent them for years.
+func sendsFetchMetadata(browser, userAgent string) bool {
+	if browser != "safari" {
+		return true
+	}
+	m := safariVersionRe.FindStringSubmatch(userAgent)
+	if m == nil {
+		return false
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return major > 16 || (major == 16 && minor >= 4)
+}
+
+func isTrustworthy(u *url.URL) bool {
+	if u.Scheme == "https" || u.Scheme == "wss" {
+		return true
+	}
+	host := u.Hostname()
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func originOf(u *url.URL) string {
+	return u.Scheme + "://" + u.Host
+}
+
+// fetchSite compares the request URL with the initiating page. Sites are
+// approximated by the last two labels of the host name; no public suffix
+// list is consulted.
+func fetchSite(target, initiator *url.URL) string {
+	if target.Scheme == initiator.Scheme && strings.EqualFold(target.Host, initiator.Host) {
+		return SiteSameOrigin
+	}
+	if target.Scheme == initiator.Scheme && registrableDomain(target.Hostname()) == registrableDomain(initiator.Hostname()) {
+		return SiteSameSite
+	}
+	return SiteCrossSite
+}
+
+func registrableDomain(host string) string {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if net.ParseIP(host) != nil {
+		return host
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) <= 2 {
+		return host
+	}
+	return strings.Join(labels[len(labels)-2:], ".")
+}
+
+// refererFor applies the strict-origin-when-cross-origin policy browsers use
+// by default: the full URL for same-origin requests, only the origin for
+// cross-origin ones and nothing when going from HTTPS to HTTP.
+func refererFor(target, referer *url.URL) string {
+	if referer == nil {
+		return ""
+	}
+	if referer.Scheme == "https" && target.Scheme != "https" {
+		return ""
+	}
+	if target.Scheme == referer.Scheme && strings.EqualFold(target.Host, referer.Host) {
+		ref := *referer
+		ref.User = nil
+		ref.Fragment = ""
+		ref.RawFragment = ""
+		return ref.String()
+	}
+	return originOf(referer) + "/"
+}
@@ -0,0 +1,284 @@
+package fingerprint
+
+import (
+	"testing"
+)
+
+const (
+	chromeUA  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	firefoxUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0"
+)
+
+func requestFingerprint(userAgent string, hdrs Headers) *Fingerprint {
+	fp := &Fingerprint{Headers: hdrs}
+	fp.Navigator.UserAgent = userAgent
+	return fp
+}
+
+func TestHeadersFor(t *testing.T) {
+	g := newTestGenerator(t)
+	chrome := requestFingerprint(chromeUA, Headers{
+		{Name: "sec-ch-ua", Value: `"Chromium";v="120"`},
+		{Name: "User-Agent", Value: chromeUA},
+		{Name: "Accept-Encoding", Value: "gzip, deflate, br"},
+		{Name: "Accept-Language", Value: "en-US,en;q=0.9"},
+	})
+	firefox := requestFingerprint(firefoxUA, Headers{
+		{Name: "User-Agent", Value: firefoxUA},
+		{Name: "Accept-Language", Value: "en-US,en;q=0.5"},
+	})
+	const (
+		page    = "https://a.example.com/form?x=1"
+		origin  = "https://a.example.com"
+		imageCh = "image/avif,image/webp,image/apng,image/svg+xml,image/*,*/*;q=0.8"
+	)
+	fetchHeaders := []string{"Sec-Fetch-Site", "Sec-Fetch-Mode", "Sec-Fetch-Dest", "Sec-Fetch-User"}
+
+	for _, tt := range []struct {
+		name   string
+		fp     *Fingerprint
+		rc     RequestContext
+		want   map[string]string
+		absent []string
+	}{
+		{
+			name: "first navigation",
+			fp:   chrome,
+			rc:   RequestContext{URL: "https://a.example.com/"},
+			want: map[string]string{
+				"Sec-Fetch-Site": SiteNone, "Sec-Fetch-Mode": "navigate", "Sec-Fetch-Dest": "document",
+				"Accept": documentAccept, "Upgrade-Insecure-Requests": "1",
+			},
+			absent: []string{"Sec-Fetch-User", "Referer", "Origin"},
+		},
+		{
+			name: "same-origin click",
+			fp:   chrome,
+			rc:   RequestContext{URL: "https://a.example.com/next", Referer: page + "#top", UserInitiated: true},
+			want: map[string]string{
+				"Sec-Fetch-Site": SiteSameOrigin, "Sec-Fetch-User": "?1", "Referer": page,
+			},
+			absent: []string{"Origin"},
+		},
+		{
+			name: "same-site navigation",
+			fp:   chrome,
+			rc:   RequestContext{URL: "https://b.example.com/", Referer: page},
+			want: map[string]string{
+				"Sec-Fetch-Site": SiteSameSite, "Referer": origin + "/",
+			},
+			absent: []string{"Sec-Fetch-User", "Origin"},
+		},
+		{
+			name: "cross-site navigation",
+			fp:   chrome,
+			rc:   RequestContext{URL: "https://other.org/", Referer: page, UserInitiated: true},
+			want: map[string]string{
+				"Sec-Fetch-Site": SiteCrossSite, "Sec-Fetch-User": "?1", "Referer": origin + "/",
+			},
+			absent: []string{"Origin"},
+		},
+		{
+			name: "iframe",
+			fp:   chrome,
+			rc:   RequestContext{URL: "https://other.org/embed", Referer: page, Destination: DestinationIframe},
+			want: map[string]string{
+				"Sec-Fetch-Mode": "navigate", "Sec-Fetch-Dest": "iframe", "Upgrade-Insecure-Requests": "1",
+			},
+		},
+		{
+			name: "image",
+			fp:   chrome,
+			rc:   RequestContext{URL: "https://cdn.other.org/a.png", Referer: page, Destination: DestinationImage, UserInitiated: true},
+			want: map[string]string{
+				"Sec-Fetch-Site": SiteCrossSite, "Sec-Fetch-Mode": "no-cors", "Sec-Fetch-Dest": "image",
+				"Accept": imageCh, "Referer": origin + "/",
+			},
+			absent: []string{"Sec-Fetch-User", "Upgrade-Insecure-Requests", "Origin"},
+		},
+		{
+			name: "script",
+			fp:   chrome,
+			rc:   RequestContext{URL: "https://a.example.com/app.js", Referer: page, Destination: DestinationScript},
+			want: map[string]string{
+				"Sec-Fetch-Mode": "no-cors", "Sec-Fetch-Dest": "script", "Accept": "*/*",
+			},
+			absent: []string{"Upgrade-Insecure-Requests"},
+		},
+		{
+			name: "style",
+			fp:   chrome,
+			rc:   RequestContext{URL: "https://a.example.com/app.css", Referer: page, Destination: DestinationStyle},
+			want: map[string]string{
+				"Sec-Fetch-Mode": "no-cors", "Sec-Fetch-Dest": "style", "Accept": "text/css,*/*;q=0.1",
+			},
+		},
+		{
+			name: "cross-site font",
+			fp:   chrome,
+			rc:   RequestContext{URL: "https://fonts.other.org/a.woff2", Referer: page, Destination: DestinationFont},
+			want: map[string]string{
+				"Sec-Fetch-Mode": "cors", "Sec-Fetch-Dest": "font", "Accept": "*/*", "Origin": origin,
+			},
+		},
+		{
+			name: "same-origin fetch",
+			fp:   chrome,
+			rc:   RequestContext{URL: "https://a.example.com/api", Referer: page, Destination: DestinationEmpty},
+			want: map[string]string{
+				"Sec-Fetch-Site": SiteSameOrigin, "Sec-Fetch-Mode": "cors", "Sec-Fetch-Dest": "empty",
+			},
+			absent: []string{"Origin"},
+		},
+		{
+			name: "same-site fetch",
+			fp:   chrome,
+			rc:   RequestContext{URL: "https://api.example.com/v1", Referer: page, Destination: DestinationEmpty},
+			want: map[string]string{
+				"Sec-Fetch-Site": SiteSameSite, "Origin": origin,
+			},
+		},
+		{
+			name: "cross-site POST",
+			fp:   chrome,
+			rc:   RequestContext{Method: "post", URL: "https://other.org/submit", Referer: page},
+			want: map[string]string{
+				"Sec-Fetch-Site": SiteCrossSite, "Origin": origin, "Referer": origin + "/",
+			},
+		},
+		{
+			name: "same-origin POST",
+			fp:   chrome,
+			rc:   RequestContext{Method: "POST", URL: "https://a.example.com/submit", Referer: page},
+			want: map[string]string{
+				"Origin": origin, "Referer": page,
+			},
+		},
+		{
+			name: "POST without referer",
+			fp:   chrome,
+			rc:   RequestContext{Method: "POST", URL: "https://a.example.com/submit"},
+			want: map[string]string{
+				"Origin": "null",
+			},
+			absent: []string{"Referer"},
+		},
+		{
+			name: "site override",
+			fp:   chrome,
+			rc:   RequestContext{URL: "https://a.example.com/a.png", Destination: DestinationImage, Site: SiteSameOrigin},
+			want: map[string]string{
+				"Sec-Fetch-Site": SiteSameOrigin,
+			},
+			absent: []string{"Referer"},
+		},
+		{
+			name:   "downgrade to HTTP",
+			fp:     chrome,
+			rc:     RequestContext{URL: "http://other.org/", Referer: page},
+			want:   map[string]string{"Upgrade-Insecure-Requests": "1", "User-Agent": chromeUA},
+			absent: append([]string{"Referer", "sec-ch-ua"}, fetchHeaders...),
+		},
+		{
+			name:   "HTTP page",
+			fp:     chrome,
+			rc:     RequestContext{URL: "http://a.example.com/next", Referer: "http://a.example.com/"},
+			want:   map[string]string{"Referer": "http://a.example.com/"},
+			absent: append([]string{"sec-ch-ua"}, fetchHeaders...),
+		},
+		{
+			name: "HTTP on localhost",
+			fp:   chrome,
+			rc:   RequestContext{URL: "http://localhost:8080/"},
+			want: map[string]string{"Sec-Fetch-Site": SiteNone, "sec-ch-ua": `"Chromium";v="120"`},
+		},
+		{
+			name: "Firefox image",
+			fp:   firefox,
+			rc:   RequestContext{URL: "https://a.example.com/a.png", Referer: page, Destination: DestinationImage},
+			want: map[string]string{
+				"Accept": "image/avif,image/webp,image/png,image/svg+xml,image/*;q=0.8,*/*;q=0.5",
+			},
+		},
+		{
+			name: "Firefox font",
+			fp:   firefox,
+			rc:   RequestContext{URL: "https://a.example.com/a.woff2", Referer: page, Destination: DestinationFont},
+			want: map[string]string{
+				"Accept": "application/font-woff2;q=1.0,application/font-woff;q=0.9,*/*;q=0.8",
+			},
+		},
+	} {
+		hdrs, err := g.HeadersFor(tt.fp, tt.rc)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for name, want := range tt.want {
+			if got, ok := hdrs.Lookup(name); !ok || got != want {
+				t.Errorf("%s: %s = %q (present %v), want %q", tt.name, name, got, ok, want)
+			}
+		}
+		for _, name := range tt.absent {
+			if got, ok := hdrs.Lookup(name); ok {
+				t.Errorf("%s: %s = %q, want it absent", tt.name, name, got)
+			}
+		}
+	}
+}
+
+func TestHeadersForHTTP2(t *testing.T) {
+	g := newTestGenerator(t)
+	fp := requestFingerprint(chromeUA, Headers{
+		{Name: ":method", Value: "GET"},
+		{Name: ":authority", Value: "old.example.com"},
+		{Name: ":scheme", Value: "https"},
+		{Name: ":path", Value: "/"},
+		{Name: "user-agent", Value: chromeUA},
+		{Name: "accept-language", Value: "en-US,en;q=0.9"},
+	})
+	hdrs, err := g.HeadersFor(fp, RequestContext{
+		Method:  "POST",
+		URL:     "https://a.example.com:8443/submit?x=1",
+		Referer: "https://a.example.com:8443/form",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]string{
+		":method":        "POST",
+		":authority":     "a.example.com:8443",
+		":scheme":        "https",
+		":path":          "/submit?x=1",
+		"origin":         "https://a.example.com:8443",
+		"sec-fetch-site": SiteSameOrigin,
+	} {
+		found := false
+		for _, hdr := range hdrs {
+			if hdr.Name == name {
+				found = true
+				if hdr.Value != want {
+					t.Errorf("%s = %q, want %q", name, hdr.Value, want)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("no header spelled %q in %v", name, hdrs.Names())
+		}
+	}
+}
+
+func TestHeadersForErrors(t *testing.T) {
+	g := newTestGenerator(t)
+	fp := requestFingerprint(chromeUA, nil)
+	for _, rc := range []RequestContext{
+		{URL: "/relative"},
+		{URL: "https://a.example.com/", Referer: "%zz"},
+		{URL: "https://a.example.com/a.png", Destination: DestinationImage},
+		{URL: "https://a.example.com/", Destination: "video"},
+	} {
+		if _, err := g.HeadersFor(fp, rc); err == nil {
+			t.Errorf("HeadersFor(%+v) succeeded", rc)
+		}
+	}
+}
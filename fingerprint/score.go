@@ -0,0 +1,208 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/bayesian"
+)
+
+// scoredFields reads the value each fingerprint network node was turned into.
+// The hidden *BROWSER, *OPERATING_SYSTEM and *DEVICE nodes are summed over.
+var scoredFields = []struct {
+	node  string
+	field func(fp *Fingerprint) interface{}
+}{
+	{"userAgentData", func(fp *Fingerprint) interface{} { return fp.Navigator.UserAgentData }},
+	{"appCodeName", func(fp *Fingerprint) interface{} { return fp.Navigator.AppCodeName }},
+	{"appName", func(fp *Fingerprint) interface{} { return fp.Navigator.AppName }},
+	{"appVersion", func(fp *Fingerprint) interface{} { return fp.Navigator.AppVersion }},
+	{"webdriver", func(fp *Fingerprint) interface{} { return fp.Navigator.Webdriver }},
+	{"platform", func(fp *Fingerprint) interface{} { return fp.Navigator.Platform }},
+	{"oscpu", func(fp *Fingerprint) interface{} { return fp.Navigator.Oscpu }},
+	{"product", func(fp *Fingerprint) interface{} { return fp.Navigator.Product }},
+	{"productSub", func(fp *Fingerprint) interface{} { return fp.Navigator.ProductSub }},
+	{"vendor", func(fp *Fingerprint) interface{} { return fp.Navigator.Vendor }},
+	{"vendorSub", func(fp *Fingerprint) interface{} { return fp.Navigator.VendorSub }},
+	{"doNotTrack", func(fp *Fingerprint) interface{} { return fp.Navigator.DoNotTrack }},
+	{"globalPrivacyControl", func(fp *Fingerprint) interface{} { return fp.Navigator.GlobalPrivacyControl }},
+	{"hardwareConcurrency", func(fp *Fingerprint) interface{} { return fp.Navigator.HardwareConcurrency }},
+	{"deviceMemory", func(fp *Fingerprint) interface{} { return fp.Navigator.DeviceMemory }},
+	{"maxTouchPoints", func(fp *Fingerprint) interface{} { return fp.Navigator.MaxTouchPoints }},
+	{"languages", func(fp *Fingerprint) interface{} { return fp.Navigator.Languages }},
+	{"screen", func(fp *Fingerprint) interface{} { return fp.Screen }},
+	{"extraProperties", func(fp *Fingerprint) interface{} { return fp.Navigator.ExtraProperties }},
+	{"videoCodecs", func(fp *Fingerprint) interface{} { return fp.VideoCodecs }},
+	{"audioCodecs", func(fp *Fingerprint) interface{} { return fp.AudioCodecs }},
+	{"pluginsData", func(fp *Fingerprint) interface{} { return fp.PluginsData }},
+	{"battery", func(fp *Fingerprint) interface{} { return fp.Battery }},
+	{"videoCard", func(fp *Fingerprint) interface{} { return fp.VideoCard }},
+	{"fonts", func(fp *Fingerprint) interface{} { return fp.Fonts }},
+	{"multimediaDevices", func(fp *Fingerprint) interface{} { return fp.MultimediaDevices }},
+}
+
+// scoreIndex maps the encoded field of every node value back to the values
+// producing it, which are usually one.
+type scoreIndex struct {
+	values map[string]map[string][]string
+	zero   map[string]string
+}
+
+type lazyScoreIndex struct {
+	once sync.Once
+	idx  *scoreIndex
+	err  error
+}
+
+func fieldKey(v interface{}) string {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
+
+func buildScoreIndex(net *bayesian.BayesianNetwork) (*scoreIndex, error) {
+	idx := &scoreIndex{
+		values: make(map[string]map[string][]string, len(scoredFields)),
+		zero:   make(map[string]string, len(scoredFields)),
+	}
+	var empty Fingerprint
+	for _, f := range scoredFields {
+		node := net.Node(f.node)
+		if node == nil {
+			continue
+		}
+		keys := make(map[string][]string)
+		for _, v := range node.PossibleValues() {
+			fp, err := transformFingerprint(map[string]string{f.node: v}, nil, false, false)
+			if err != nil {
+				// Values that cannot be decoded never reach a fingerprint.
+				continue
+			}
+			key := fieldKey(f.field(fp))
+			keys[key] = append(keys[key], v)
+		}
+		idx.values[f.node] = keys
+		idx.zero[f.node] = fieldKey(f.field(&empty))
+	}
+	return idx, nil
+}
+
+// Score returns the natural logarithm of the probability that the networks
+// produce fp: its headers under the input and header networks, and its other
+// fields under the fingerprint network given its user agent. Higher is more
+// common; a fingerprint with a value the networks never produce, or a
+// combination they never sample together, scores -Inf.
+//
+// The score is taken under the unconstrained networks, so fingerprints from
+// generators with browser or device constraints are comparable. Fields the
+// generator's own options set after sampling are not scored: the languages
+// when they are the generator's WithLocales or WithCountry locales, and the
+// screen with the Camoufox options. Empty fields the networks never leave
+// empty are treated as removed and skipped as well. A user agent with the
+// version set by SetFirefoxVersion is scored as the user agents it was
+// rewritten from. A user agent the fingerprint network does not know scores
+// -Inf, even though Generate samples the other fields for it from the
+// network's fallback branches.
+func (g *Generator) Score(fp *Fingerprint) (float64, error) {
+	if fp == nil {
+		return 0, fmt.Errorf("invalid fingerprint: nil")
+	}
+	if fp.Navigator.UserAgent == "" {
+		return 0, &FieldError{Field: "navigator.userAgent"}
+	}
+	if g.networkErr != nil {
+		return 0, g.networkErr
+	}
+	g.scoring.once.Do(func() {
+		g.scoring.idx, g.scoring.err = buildScoreIndex(g.network)
+	})
+	if g.scoring.err != nil {
+		return 0, g.scoring.err
+	}
+
+	headerProb, err := g.headers.Likelihood(fp.Headers, g.enableWhitelist)
+	if err != nil {
+		return 0, err
+	}
+	if headerProb == 0 {
+		return math.Inf(-1), nil
+	}
+
+	userAgents := g.sampledUserAgents(fp.Navigator.UserAgent)
+	uaProb, err := g.network.Probability(map[string][]string{"userAgent": userAgents})
+	var constraintErr *ConstraintError
+	if errors.As(err, &constraintErr) {
+		return math.Inf(-1), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	skip := map[string]bool{
+		"languages": len(g.locales()) > 0 && sameStrings(fp.Navigator.Languages, g.locales()),
+		"screen":    g.enableWhitelist || g.screenConstraints != nil || g.windowSize != nil,
+	}
+	evidence := map[string][]string{"userAgent": userAgents}
+	for _, f := range scoredFields {
+		keys, ok := g.scoring.idx.values[f.node]
+		if !ok || skip[f.node] {
+			continue
+		}
+		key := fieldKey(f.field(fp))
+		values, known := keys[key]
+		switch {
+		case known:
+			evidence[f.node] = values
+		case key == g.scoring.idx.zero[f.node] || key == "{}" || key == "[]":
+			// Removed after sampling, e.g. by the property whitelist.
+		default:
+			return math.Inf(-1), nil
+		}
+	}
+	jointProb, err := g.network.Probability(evidence)
+	if err != nil {
+		return 0, err
+	}
+	if jointProb == 0 || uaProb == 0 {
+		return math.Inf(-1), nil
+	}
+	return math.Log(headerProb) + math.Log(jointProb) - math.Log(uaProb), nil
+}
+
+// sampledUserAgents returns the fingerprint network's user agents that
+// Generate turns into ua. They differ from ua only when SetFirefoxVersion
+// rewrites the version of sampled Firefox user agents.
+func (g *Generator) sampledUserAgents(ua string) []string {
+	version := g.currentFirefoxVersion()
+	node := g.network.Node("userAgent")
+	if version == "" || !g.onlyBrowser("firefox") || node == nil {
+		return []string{ua}
+	}
+	var sampled []string
+	for _, v := range node.PossibleValues() {
+		if rewriteFirefoxVersion(v, version) == ua {
+			sampled = append(sampled, v)
+		}
+	}
+	if len(sampled) == 0 {
+		return []string{ua}
+	}
+	return sampled
+}
+
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
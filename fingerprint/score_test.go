@@ -0,0 +1,90 @@
+package fingerprint
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestScore(t *testing.T) {
+	g := newTestGenerator(t, WithSeed(3))
+	for i := 0; i < 30; i++ {
+		fp, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		score, err := g.Score(fp)
+		if err != nil {
+			t.Fatalf("Score: %v", err)
+		}
+		if math.IsInf(score, -1) || math.IsNaN(score) || score > 0 {
+			t.Errorf("%q scores %v", fp.Navigator.UserAgent, score)
+		}
+	}
+
+	if _, err := g.Score(nil); err == nil {
+		t.Error("Score(nil) succeeded")
+	}
+}
+
+func TestScoreImpossible(t *testing.T) {
+	g := newTestGenerator(t, WithSeed(4))
+	for _, tc := range []struct {
+		name   string
+		modify func(fp *Fingerprint)
+	}{
+		{"user agent", func(fp *Fingerprint) { fp.Navigator.UserAgent = "curl/8.0" }},
+		{"platform", func(fp *Fingerprint) { fp.Navigator.Platform = "PDP-11" }},
+		{"header", func(fp *Fingerprint) {
+			for i := range fp.Headers {
+				if strings.EqualFold(fp.Headers[i].Name, "accept-encoding") {
+					fp.Headers[i].Value = "no such coding"
+				}
+			}
+		}},
+	} {
+		fp, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		tc.modify(fp)
+		if score, err := g.Score(fp); err != nil || !math.IsInf(score, -1) {
+			t.Errorf("%s: Score = %v, %v; want -Inf", tc.name, score, err)
+		}
+	}
+}
+
+func TestScoreCamoufox(t *testing.T) {
+	g := newTestGenerator(t, WithSeed(2), WithCamoufoxConstraints())
+	for _, version := range []string{"", "135"} {
+		g.SetFirefoxVersion(version)
+		scored := 0
+		for i := 0; i < 30; i++ {
+			fp, err := g.Generate()
+			if err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+			ua := fp.Navigator.UserAgent
+			if strings.Contains(ua, ".0.0") || version != "" && !strings.Contains(ua, "Firefox/"+version+".0") {
+				t.Fatalf("version %q: user agent %q", version, ua)
+			}
+			score, err := g.Score(fp)
+			if err != nil {
+				t.Fatalf("Score: %v", err)
+			}
+			// The header network has user agents the test fingerprint
+			// network lacks.
+			p, err := g.network.Probability(map[string][]string{"userAgent": g.sampledUserAgents(ua)})
+			known := err == nil && p > 0
+			if known == math.IsInf(score, -1) || score > 0 {
+				t.Errorf("version %q: %q, known %v, scores %v", version, ua, known, score)
+			}
+			if known {
+				scored++
+			}
+		}
+		if scored == 0 {
+			t.Errorf("version %q: no user agent the network knows", version)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Session keeps one browser identity across many requests. It remembers the
+// current page so that navigations and subresource requests carry the
+// Referer and Sec-Fetch-Site a browser would send, and it stores cookies.
+// Session implements http.CookieJar and can be saved as JSON and restored
+// with Generator.RestoreSession.
+//
+// Session is safe for concurrent use.
+type Session struct {
+	generator *Generator
+	fp        *Fingerprint
+	jar       *cookiejar.Jar
+
+	mu sync.Mutex
+	// page is the document currently shown and lastNav the request that
+	// loaded it, repeated on reload.
+	page    string
+	lastNav RequestContext
+	cookies map[cookieKey]savedCookie
+}
+
+// cookieKey identifies a cookie the way the jar does, so that a cookie set
+// again, or deleted, replaces the saved one.
+type cookieKey struct {
+	host, domain, path, name string
+}
+
+func newCookieKey(u *url.URL, c *http.Cookie) cookieKey {
+	key := cookieKey{host: strings.ToLower(u.Hostname()), path: cookiePath(u, c.Path), name: c.Name}
+	if c.Domain != "" {
+		// A domain cookie is the same whichever host set it.
+		key.host, key.domain = "", strings.ToLower(strings.TrimPrefix(c.Domain, "."))
+	}
+	return key
+}
+
+// cookiePath returns the path a cookie from u applies to: its Path attribute
+// or, when that is missing or does not start with a slash, the default path
+// of RFC 6265 section 5.1.4, the directory of u's path.
+func cookiePath(u *url.URL, path string) string {
+	if strings.HasPrefix(path, "/") {
+		return path
+	}
+	dir := u.Path
+	i := strings.LastIndex(dir, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return dir[:i]
+}
+
+type savedCookie struct {
+	URL    string       `json:"url"`
+	Cookie *http.Cookie `json:"cookie"`
+}
+
+// NewSession starts a session for fp with no current page and no cookies.
+func (g *Generator) NewSession(fp *Fingerprint) (*Session, error) {
+	if fp == nil {
+		return nil, fmt.Errorf("invalid fingerprint: nil")
+	}
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &Session{
+		generator: g,
+		fp:        fp,
+		jar:       jar,
+		cookies:   make(map[cookieKey]savedCookie),
+	}, nil
+}
+
+// Fingerprint returns the fingerprint the session was started with.
+func (s *Session) Fingerprint() *Fingerprint {
+	return s.fp
+}
+
+// Page returns the URL of the current document, or "" before the first
+// navigation.
+func (s *Session) Page() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.page
+}
+
+// Navigate returns the headers for loading rawURL as the top-level document,
+// as if the user followed a link on the current page or, on the first
+// navigation, typed the address. rawURL becomes the current page.
+func (s *Session) Navigate(rawURL string) (Headers, error) {
+	s.mu.Lock()
+	rc := RequestContext{
+		URL:           rawURL,
+		Referer:       s.page,
+		Destination:   DestinationDocument,
+		UserInitiated: true,
+	}
+	s.mu.Unlock()
+	hdrs, err := s.headersFor(rc)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.page = rawURL
+	s.lastNav = rc
+	s.mu.Unlock()
+	return hdrs, nil
+}
+
+// Redirected records that the last navigation ended up at rawURL, which
+// becomes the current page.
+func (s *Session) Redirected(rawURL string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.page = rawURL
+	s.lastNav.URL = rawURL
+}
+
+// Reload returns the headers for reloading the current page, which repeat
+// the original navigation and ask caches to revalidate.
+func (s *Session) Reload() (Headers, error) {
+	s.mu.Lock()
+	rc := s.lastNav
+	s.mu.Unlock()
+	if rc.URL == "" {
+		return nil, fmt.Errorf("reload before the first navigation")
+	}
+	hdrs, err := s.headersFor(rc)
+	if err != nil {
+		return nil, err
+	}
+	setHeader(&hdrs, "Cache-Control", "max-age=0")
+	return s.generator.Order(s.fp, hdrs), nil
+}
+
+// Request returns the headers for a request made by the current page, such
+// as an image, a script or a fetch() call. An empty Referer in rc is filled
+// with the current page; document requests do not change it.
+func (s *Session) Request(rc RequestContext) (Headers, error) {
+	if rc.Referer == "" {
+		rc.Referer = s.Page()
+	}
+	return s.headersFor(rc)
+}
+
+func (s *Session) headersFor(rc RequestContext) (Headers, error) {
+	hdrs, err := s.generator.HeadersFor(s.fp, rc)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(rc.URL)
+	if err != nil {
+		return nil, err
+	}
+	if cookies := s.jar.Cookies(u); len(cookies) > 0 {
+		pairs := make([]string, len(cookies))
+		for i, c := range cookies {
+			pairs[i] = c.Name + "=" + c.Value
+		}
+		setHeader(&hdrs, "Cookie", strings.Join(pairs, "; "))
+		hdrs = s.generator.Order(s.fp, hdrs)
+	}
+	return hdrs, nil
+}
+
+// SetCookies stores cookies received in a response from u.
+func (s *Session) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	s.jar.SetCookies(u, cookies)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for _, c := range cookies {
+		key := newCookieKey(u, c)
+		saved := *c
+		switch {
+		case c.MaxAge < 0:
+			delete(s.cookies, key)
+			continue
+		case c.MaxAge > 0:
+			// Max-Age is relative to when the cookie was received, so it
+			// is stored as an absolute expiry.
+			saved.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+			saved.MaxAge = 0
+		}
+		if !saved.Expires.IsZero() && !saved.Expires.After(now) {
+			delete(s.cookies, key)
+			continue
+		}
+		s.cookies[key] = savedCookie{URL: u.String(), Cookie: &saved}
+	}
+}
+
+// Cookies returns the cookies to send in a request to u.
+func (s *Session) Cookies(u *url.URL) []*http.Cookie {
+	return s.jar.Cookies(u)
+}
+
+type sessionJSON struct {
+	Fingerprint *Fingerprint  `json:"fingerprint"`
+	Page        string        `json:"page,omitempty"`
+	Referer     string        `json:"referer,omitempty"`
+	Cookies     []savedCookie `json:"cookies,omitempty"`
+}
+
+// MarshalJSON saves the fingerprint, the current page with the referer it was
+// loaded from, and the cookies that have not expired.
+func (s *Session) MarshalJSON() ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := sessionJSON{
+		Fingerprint: s.fp,
+		Page:        s.page,
+		Referer:     s.lastNav.Referer,
+	}
+	now := time.Now()
+	for _, c := range s.cookies {
+		if c.Cookie.Expires.IsZero() || c.Cookie.Expires.After(now) {
+			out.Cookies = append(out.Cookies, c)
+		}
+	}
+	sort.Slice(out.Cookies, func(i, j int) bool {
+		a, b := out.Cookies[i], out.Cookies[j]
+		if a.URL != b.URL {
+			return a.URL < b.URL
+		}
+		return a.Cookie.Name < b.Cookie.Name
+	})
+	return json.Marshal(out)
+}
+
+// RestoreSession rebuilds a session saved with json.Marshal. Expired
+// cookies are dropped.
+func (g *Generator) RestoreSession(data []byte) (*Session, error) {
+	var in sessionJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return nil, fmt.Errorf("decoding session: %w", err)
+	}
+	if in.Fingerprint == nil {
+		return nil, fmt.Errorf("decoding session: missing fingerprint")
+	}
+	if err := in.Fingerprint.checkRequired(); err != nil {
+		return nil, fmt.Errorf("decoding session: %w", err)
+	}
+	s, err := g.NewSession(in.Fingerprint)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range in.Cookies {
+		u, err := url.Parse(c.URL)
+		if err != nil || c.Cookie == nil {
+			return nil, fmt.Errorf("decoding session: invalid cookie for %q", c.URL)
+		}
+		s.SetCookies(u, []*http.Cookie{c.Cookie})
+	}
+	s.page = in.Page
+	if in.Page != "" {
+		s.lastNav = RequestContext{
+			URL:           in.Page,
+			Referer:       in.Referer,
+			Destination:   DestinationDocument,
+			UserInitiated: true,
+		}
+	}
+	return s, nil
+}
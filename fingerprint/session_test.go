@@ -0,0 +1,207 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestCookiePath(t *testing.T) {
+	for _, tc := range []struct {
+		url, path, want string
+	}{
+		{"https://example.com/a/b/page", "", "/a/b"},
+		{"https://example.com/a/b/", "", "/a/b"},
+		{"https://example.com/page", "", "/"},
+		{"https://example.com", "", "/"},
+		{"https://example.com/a/page", "relative", "/a"},
+		{"https://example.com/a/page", "/x", "/x"},
+	} {
+		u, _ := url.Parse(tc.url)
+		if got := cookiePath(u, tc.path); got != tc.want {
+			t.Errorf("cookiePath(%s, %q) = %q, want %q", tc.url, tc.path, got, tc.want)
+		}
+	}
+}
+
+func TestSessionCookiesSurviveRestore(t *testing.T) {
+	g := newTestGenerator(t, WithSeed(1))
+	fp, err := g.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := g.NewSession(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	page, _ := url.Parse("https://shop.example.com/cart/view")
+	other, _ := url.Parse("https://www.example.com/")
+
+	// Without a Path the cookie applies to /cart, so the second one replaces
+	// it rather than adding another.
+	s.SetCookies(page, []*http.Cookie{{Name: "id", Value: "1"}})
+	s.SetCookies(page, []*http.Cookie{{Name: "id", Value: "2", Path: "/cart"}})
+	// A domain cookie set by another host is the same cookie.
+	s.SetCookies(page, []*http.Cookie{{Name: "lang", Value: "en", Domain: "example.com", Path: "/"}})
+	s.SetCookies(other, []*http.Cookie{{Name: "lang", Value: "de", Domain: ".Example.com", Path: "/"}})
+	// Deleting without a Path removes the cookie set with Path=/cart.
+	s.SetCookies(page, []*http.Cookie{{Name: "gone", Value: "x", Path: "/cart"}})
+	s.SetCookies(page, []*http.Cookie{{Name: "gone", MaxAge: -1}})
+
+	raw, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := g.RestoreSession(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved sessionJSON
+	if err := json.Unmarshal(raw, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Cookies) != 2 {
+		t.Errorf("saved %d cookies, want 2", len(saved.Cookies))
+	}
+	for _, sess := range []*Session{s, restored} {
+		got := map[string]string{}
+		for _, c := range sess.Cookies(page) {
+			got[c.Name] = c.Value
+		}
+		if len(got) != 2 || got["id"] != "2" || got["lang"] != "de" {
+			t.Errorf("cookies for %s = %v, want id=2 lang=de", page, got)
+		}
+	}
+}
+
+// checkHeaders fails unless hdrs have the values in want, where "" means the
+// header must be absent.
+func checkHeaders(t *testing.T, step string, hdrs Headers, want map[string]string) {
+	t.Helper()
+	for name, value := range want {
+		got, ok := hdrs.Lookup(name)
+		if value == "" && ok {
+			t.Errorf("%s: %s = %q, want it absent", step, name, got)
+		} else if value != "" && got != value {
+			t.Errorf("%s: %s = %q, want %q", step, name, got, value)
+		}
+	}
+}
+
+func newTestSession(t *testing.T) (*Generator, *Session) {
+	t.Helper()
+	g := newTestGenerator(t, WithSeed(1), WithBrowser("chrome"))
+	fp, err := g.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := g.NewSession(fp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, s
+}
+
+func TestSessionNavigation(t *testing.T) {
+	_, s := newTestSession(t)
+	if _, err := s.Reload(); err == nil {
+		t.Error("Reload before the first navigation succeeded")
+	}
+
+	steps := []struct {
+		url  string
+		want map[string]string
+	}{
+		{"https://a.example.com/", map[string]string{"Sec-Fetch-Site": SiteNone, "Referer": ""}},
+		{"https://a.example.com/next?q=1", map[string]string{"Sec-Fetch-Site": SiteSameOrigin, "Referer": "https://a.example.com/"}},
+		{"https://other.org/", map[string]string{"Sec-Fetch-Site": SiteCrossSite, "Referer": "https://a.example.com/"}},
+	}
+	for _, step := range steps {
+		hdrs, err := s.Navigate(step.url)
+		if err != nil {
+			t.Fatalf("Navigate(%s): %v", step.url, err)
+		}
+		checkHeaders(t, "Navigate "+step.url, hdrs, step.want)
+		checkHeaders(t, "Navigate "+step.url, hdrs, map[string]string{"Sec-Fetch-User": "?1", "Cache-Control": ""})
+		if got := s.Page(); got != step.url {
+			t.Errorf("after Navigate(%s) page = %q", step.url, got)
+		}
+	}
+
+	hdrs, err := s.Request(RequestContext{URL: "https://other.org/logo.png", Destination: DestinationImage})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeaders(t, "Request", hdrs, map[string]string{
+		"Referer":        "https://other.org/",
+		"Sec-Fetch-Site": SiteSameOrigin,
+		"Sec-Fetch-Dest": "image",
+	})
+	if got := s.Page(); got != "https://other.org/" {
+		t.Errorf("Request changed the page to %q", got)
+	}
+
+	s.Redirected("https://www.other.org/home")
+	if got := s.Page(); got != "https://www.other.org/home" {
+		t.Errorf("after Redirected page = %q", got)
+	}
+	hdrs, err = s.Request(RequestContext{URL: "https://www.other.org/api", Destination: DestinationEmpty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeaders(t, "Request after redirect", hdrs, map[string]string{"Referer": "https://www.other.org/home"})
+
+	// The reload repeats the navigation from a.example.com that was
+	// redirected to www.other.org.
+	hdrs, err = s.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHeaders(t, "Reload", hdrs, map[string]string{
+		"Cache-Control":  "max-age=0",
+		"Referer":        "https://a.example.com/",
+		"Sec-Fetch-Site": SiteCrossSite,
+	})
+	if v, ok := hdrs.Lookup(":authority"); ok && v != "www.other.org" {
+		t.Errorf("Reload :authority = %q, want www.other.org", v)
+	}
+}
+
+func TestSessionReloadAfterRestore(t *testing.T) {
+	g, s := newTestSession(t)
+	for _, u := range []string{"https://a.example.com/", "https://b.example.com/page"} {
+		if _, err := s.Navigate(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+	raw, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := g.RestoreSession(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := restored.Page(); got != "https://b.example.com/page" {
+		t.Errorf("restored page = %q", got)
+	}
+
+	want, err := s.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := restored.Reload()
+	if err != nil {
+		t.Fatalf("Reload after restore: %v", err)
+	}
+	checkHeaders(t, "Reload after restore", got, map[string]string{
+		"Cache-Control":  "max-age=0",
+		"Referer":        "https://a.example.com/",
+		"Sec-Fetch-Site": SiteSameSite,
+	})
+	if !reflect.DeepEqual(got.Map(), want.Map()) {
+		t.Errorf("restored Reload headers differ:\ngot  %v\nwant %v", got.Map(), want.Map())
+	}
+}
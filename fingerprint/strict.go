@@ -0,0 +1,65 @@
+package fingerprint
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// sampledFields maps the fingerprint network nodes checked in strict mode to
+// their JSON paths. Required nodes must be present; the others may be
+// missing but must parse when they are not.
+var sampledFields = []struct {
+	node     string
+	path     string
+	required bool
+	parse    func(string) error
+}{
+	{"screen", "screen", true, parseStringified},
+	{"languages", "navigator.languages", true, parseStringified},
+	{"platform", "navigator.platform", true, nil},
+	{"hardwareConcurrency", "navigator.hardwareConcurrency", true, parseInt},
+	{"maxTouchPoints", "navigator.maxTouchPoints", true, parseInt},
+	{"videoCard", "videoCard", true, parseStringified},
+	{"userAgentData", "navigator.userAgentData", false, parseStringified},
+	{"deviceMemory", "navigator.deviceMemory", false, parseInt},
+	{"globalPrivacyControl", "navigator.globalPrivacyControl", false, parseBool},
+	{"webdriver", "navigator.webdriver", false, parseBool},
+	{"battery", "battery", false, parseStringified},
+	{"fonts", "fonts", false, parseStringified},
+}
+
+func parseStringified(s string) error {
+	if !strings.HasPrefix(s, "*STRINGIFIED*") {
+		return errors.New("not a stringified value")
+	}
+	return nil
+}
+
+func parseInt(s string) error {
+	_, err := strconv.Atoi(s)
+	return err
+}
+
+func parseBool(s string) error {
+	_, err := strconv.ParseBool(s)
+	return err
+}
+
+// checkSample reports, in strict mode, the sampled values that
+// transformFingerprint would otherwise drop.
+func checkSample(sample map[string]string) error {
+	for _, f := range sampledFields {
+		val, ok := sample[f.node]
+		if !ok || val == "" || val == "*MISSING_VALUE*" {
+			if f.required {
+				return &FieldError{Field: f.path}
+			}
+			continue
+		}
+		if f.parse != nil && f.parse(val) != nil {
+			return &FieldError{Field: f.path, Value: val}
+		}
+	}
+	return nil
+}
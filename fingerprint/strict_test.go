@@ -0,0 +1,103 @@
+package fingerprint
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"testing/fstest"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/data"
+	"github.com/yourneighborhoodchef/browserforge/internal/fixtures"
+)
+
+// withFixedNode returns options loading the test fingerprint network with
+// node always sampling value.
+func withFixedNode(t *testing.T, node, value string) []Option {
+	t.Helper()
+	raw, err := fixtures.Source.ReadFile(data.FingerprintNetworkFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var network struct {
+		Nodes []map[string]interface{} `json:"nodes"`
+	}
+	if err := json.Unmarshal(raw, &network); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, n := range network.Nodes {
+		if n["name"] == node {
+			found = true
+			n["parentNames"] = []string{}
+			n["possibleValues"] = []string{value}
+			n["conditionalProbabilities"] = map[string]float64{value: 1}
+		}
+	}
+	if !found {
+		t.Fatalf("test network has no node %q", node)
+	}
+	if raw, err = json.Marshal(network); err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{data.FingerprintNetworkFile: {Data: raw}}
+	return []Option{WithDataFS(fsys), WithDataFallback()}
+}
+
+func TestStrictFieldErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		node  string
+		value string
+		field string
+		is    error
+	}{
+		{"missing", "platform", "*MISSING_VALUE*", "navigator.platform", ErrMissingField},
+		{"unparsable", "hardwareConcurrency", "many", "navigator.hardwareConcurrency", ErrInvalidField},
+		// The screen parses but has no size, which only checkRequired catches.
+		{"empty screen", "screen", `*STRINGIFIED*{"width":0,"height":0}`, "screen", ErrMissingField},
+	} {
+		opts := append(withFixedNode(t, tt.node, tt.value), WithSeed(1), WithBrowser("chrome"))
+
+		g, err := NewWithOptions(opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := g.Generate(); err != nil {
+			t.Errorf("%s: Generate without WithStrict: %v", tt.name, err)
+		}
+
+		g, err = NewWithOptions(append(opts, WithStrict())...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = g.Generate()
+		var fieldErr *FieldError
+		if !errors.As(err, &fieldErr) {
+			t.Errorf("%s: error %v, want a *FieldError", tt.name, err)
+			continue
+		}
+		if fieldErr.Field != tt.field {
+			t.Errorf("%s: FieldError.Field = %q, want %q", tt.name, fieldErr.Field, tt.field)
+		}
+		if tt.is == ErrInvalidField && fieldErr.Value != tt.value {
+			t.Errorf("%s: FieldError.Value = %q, want %q", tt.name, fieldErr.Value, tt.value)
+		}
+		if !errors.Is(err, tt.is) {
+			t.Errorf("%s: error %v does not wrap %v", tt.name, err, tt.is)
+		}
+		if !errors.Is(err, ErrSamplingFailed) {
+			t.Errorf("%s: error %v does not wrap ErrSamplingFailed", tt.name, err)
+		}
+	}
+}
+
+func TestFieldErrorUnwrap(t *testing.T) {
+	missing := &FieldError{Field: "screen"}
+	if !errors.Is(missing, ErrMissingField) || errors.Is(missing, ErrInvalidField) {
+		t.Errorf("%v should wrap only ErrMissingField", missing)
+	}
+	invalid := &FieldError{Field: "navigator.deviceMemory", Value: "lots"}
+	if !errors.Is(invalid, ErrInvalidField) || errors.Is(invalid, ErrMissingField) {
+		t.Errorf("%v should wrap only ErrInvalidField", invalid)
+	}
+}
@@ -1,5 +1,13 @@
 package fingerprint
 
+import "github.com/yourneighborhoodchef/browserforge/internal/headers"
+
+type Header = headers.Header
+
+type Headers = headers.OrderedHeaders
+
+type Browser = headers.Browser
+
 type ScreenFingerprint struct {
 	AvailHeight      int     `json:"availHeight"`
 	AvailWidth       int     `json:"availWidth"`
@@ -66,6 +74,8 @@ type WebGLFingerprint struct {
 	Vendor   string `json:"vendor"`
 }
 
+// CanvasFingerprint carries no data yet. It is kept so that documents keep
+// their "canvas" object.
 type CanvasFingerprint struct {
 }
 
@@ -73,16 +83,29 @@ type AudioContextFingerprint struct {
 	SampleRate int `json:"sampleRate"`
 }
 
+// LocaleFingerprint describes what Intl and Date report. TimezoneOffset is
+// the value of Date.prototype.getTimezoneOffset at the generator's reference
+// time (see WithReferenceTime), so it follows daylight saving time.
 type LocaleFingerprint struct {
-	Language  string   `json:"language"`
-	Languages []string `json:"languages"`
-	TimeZone  string   `json:"timeZone,omitempty"`
+	Language       string   `json:"language"`
+	Languages      []string `json:"languages"`
+	Country        string   `json:"country,omitempty"`
+	TimeZone       string   `json:"timeZone,omitempty"`
+	TimezoneOffset int      `json:"timezoneOffset"`
+}
+
+// Geolocation is a position as reported by navigator.geolocation, with the
+// accuracy in meters.
+type Geolocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	Accuracy  float64 `json:"accuracy"`
 }
 
 type Fingerprint struct {
 	Screen            ScreenFingerprint      `json:"screen"`
 	Navigator         NavigatorFingerprint   `json:"navigator"`
-	Headers           map[string]string      `json:"headers"`
+	Headers           Headers                `json:"headers"`
 	VideoCodecs       map[string]string      `json:"videoCodecs"`
 	AudioCodecs       map[string]string      `json:"audioCodecs"`
 	PluginsData       map[string]interface{} `json:"pluginsData"`
@@ -98,6 +121,7 @@ type Fingerprint struct {
 	Canvas       CanvasFingerprint       `json:"canvas"`
 	AudioContext AudioContextFingerprint `json:"audio"`
 	Locale       LocaleFingerprint       `json:"locale"`
+	Geolocation  *Geolocation            `json:"geolocation,omitempty"`
 }
 
 type ScreenConstraints struct {
@@ -0,0 +1,428 @@
+package fingerprint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Severity ranks a Finding.
+type Severity int
+
+const (
+	// SeverityWarning marks combinations that are unusual but occur in the
+	// wild.
+	SeverityWarning Severity = iota
+	// SeverityError marks combinations no real browser produces.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// Finding is one inconsistency found by Validate. Fields name the fields
+// involved, using their JSON paths.
+type Finding struct {
+	Check    string
+	Fields   []string
+	Severity Severity
+	Message  string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s (%s)", f.Severity, f.Check, f.Message, strings.Join(f.Fields, ", "))
+}
+
+// Checks reported by Validate.
+const (
+	CheckUserAgent      = "user-agent"
+	CheckClientHints    = "client-hints"
+	CheckPlatform       = "platform"
+	CheckBrowserFields  = "browser-fields"
+	CheckScreen         = "screen"
+	CheckDevice         = "device"
+	CheckAcceptLanguage = "accept-language"
+	CheckGPU            = "gpu"
+	CheckFingerprint    = "fingerprint"
+)
+
+// Validate checks fp for combinations a real browser would not produce. It
+// returns nil when no problems are found, and a single CheckFingerprint
+// error for a nil fp.
+func Validate(fp *Fingerprint) []Finding {
+	if fp == nil {
+		return []Finding{{Check: CheckFingerprint, Severity: SeverityError, Message: "fingerprint is nil"}}
+	}
+	v := &validator{fp: fp, ua: fp.Navigator.UserAgent}
+	v.browser = browserFamily(v.ua)
+	v.os = osFamily(v.ua)
+	v.checkUserAgent()
+	v.checkClientHints()
+	v.checkPlatform()
+	v.checkBrowserFields()
+	v.checkScreen()
+	v.checkDevice()
+	v.checkAcceptLanguage()
+	v.checkGPU()
+	return v.findings
+}
+
+type validator struct {
+	fp       *Fingerprint
+	ua       string
+	browser  string
+	os       string
+	findings []Finding
+}
+
+func (v *validator) report(check string, severity Severity, fields []string, format string, args ...interface{}) {
+	v.findings = append(v.findings, Finding{
+		Check:    check,
+		Fields:   fields,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// chromium reports whether the browser runs on Blink. Every browser on iOS
+// is WebKit underneath and has Safari's navigator fields.
+func (v *validator) chromium() bool {
+	return (v.browser == "chrome" || v.browser == "edge") && v.os != "ios"
+}
+
+// engine returns the browser whose navigator fields fp should have.
+func (v *validator) engine() string {
+	if v.os == "ios" && v.browser != "" {
+		return "safari"
+	}
+	return v.browser
+}
+
+func (v *validator) mobile() bool {
+	return v.os == "android" || v.os == "ios"
+}
+
+func (v *validator) checkUserAgent() {
+	if header, ok := v.fp.Headers.Lookup("User-Agent"); ok && header != v.ua {
+		v.report(CheckUserAgent, SeverityError, []string{"headers.User-Agent", "navigator.userAgent"},
+			"User-Agent header %q differs from navigator.userAgent %q", header, v.ua)
+	}
+	if v.browser == "" {
+		v.report(CheckUserAgent, SeverityWarning, []string{"navigator.userAgent"}, "unrecognized browser in %q", v.ua)
+	}
+}
+
+var (
+	brandRe     = regexp.MustCompile(`"([^"]*)";\s*v="([^"]*)"`)
+	uaVersionRe = map[string]*regexp.Regexp{
+		"chrome":  regexp.MustCompile(`(?:Chrome|CriOS)/(\d+)`),
+		"edge":    regexp.MustCompile(`Edg(?:A|iOS)?/(\d+)`),
+		"firefox": regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+)`),
+		"safari":  regexp.MustCompile(`Version/(\d+)`),
+	}
+	// brandNames are the sec-ch-ua brands naming each browser.
+	brandNames = map[string]string{
+		"chrome": "Google Chrome",
+		"edge":   "Microsoft Edge",
+	}
+)
+
+func majorVersionOf(browser, userAgent string) string {
+	re := uaVersionRe[browser]
+	if re == nil {
+		return ""
+	}
+	if m := re.FindStringSubmatch(userAgent); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// checkClientHints reports client hints that disagree with the user agent or
+// with each other. They are only warnings: Chrome with a changed user agent,
+// headless Chrome and Chromium forks send them, and the collected data has
+// them.
+func (v *validator) checkClientHints() {
+	secChUA, hasHints := v.fp.Headers.Lookup("sec-ch-ua")
+	uad := v.fp.Navigator.UserAgentData
+	if v.browser == "" {
+		return
+	}
+	if !v.chromium() {
+		if hasHints {
+			v.report(CheckClientHints, SeverityWarning, []string{"headers.sec-ch-ua"}, "%s on %s does not send client hints", v.browser, v.os)
+		}
+		if len(uad) > 0 {
+			v.report(CheckClientHints, SeverityWarning, []string{"navigator.userAgentData"}, "%s on %s has no navigator.userAgentData", v.browser, v.os)
+		}
+		return
+	}
+
+	version := majorVersionOf(v.browser, v.ua)
+	brand := brandNames[v.browser]
+	if hasHints {
+		brands := make(map[string]string)
+		for _, m := range brandRe.FindAllStringSubmatch(secChUA, -1) {
+			brands[m[1]] = m[2]
+		}
+		if got, ok := brands[brand]; v.browser == "edge" && !ok {
+			v.report(CheckClientHints, SeverityWarning, []string{"headers.sec-ch-ua"}, "sec-ch-ua lacks the %s brand", brand)
+		} else if ok && got != version {
+			v.report(CheckClientHints, SeverityWarning, []string{"headers.sec-ch-ua", "navigator.userAgent"},
+				"sec-ch-ua has %s %s but the user agent has version %s", brand, got, version)
+		}
+		if got, ok := brands["Chromium"]; ok && got != version {
+			v.report(CheckClientHints, SeverityWarning, []string{"headers.sec-ch-ua", "navigator.userAgent"},
+				"sec-ch-ua has Chromium %s but the user agent has version %s", got, version)
+		}
+	}
+
+	if len(uad) == 0 {
+		return
+	}
+	if list, ok := uad["brands"].([]interface{}); ok {
+		for _, item := range list {
+			b, _ := item.(map[string]interface{})
+			name := fmt.Sprint(b["brand"])
+			if (name == brand || name == "Chromium") && fmt.Sprint(b["version"]) != version {
+				v.report(CheckClientHints, SeverityWarning, []string{"navigator.userAgentData.brands", "navigator.userAgent"},
+					"userAgentData has %s %v but the user agent has version %s", name, b["version"], version)
+			}
+		}
+	}
+	if platform, ok := v.fp.Headers.Lookup("sec-ch-ua-platform"); ok {
+		if want := fmt.Sprint(uad["platform"]); strings.Trim(platform, `"`) != want {
+			v.report(CheckClientHints, SeverityWarning, []string{"headers.sec-ch-ua-platform", "navigator.userAgentData.platform"},
+				"sec-ch-ua-platform %s differs from userAgentData platform %q", platform, want)
+		}
+	}
+	if mobileHint, ok := v.fp.Headers.Lookup("sec-ch-ua-mobile"); ok {
+		mobile := getBoolOrDefault(uad, "mobile", false)
+		if (mobileHint == "?1") != mobile {
+			v.report(CheckClientHints, SeverityWarning, []string{"headers.sec-ch-ua-mobile", "navigator.userAgentData.mobile"},
+				"sec-ch-ua-mobile %s differs from userAgentData mobile %t", mobileHint, mobile)
+		}
+	}
+	if want, ok := uaPlatforms[v.os]; ok && fmt.Sprint(uad["platform"]) != want {
+		v.report(CheckClientHints, SeverityWarning, []string{"navigator.userAgentData.platform", "navigator.userAgent"},
+			"userAgentData platform %v does not match %s", uad["platform"], v.os)
+	}
+}
+
+// uaPlatforms are the userAgentData platforms Chromium reports per OS.
+var uaPlatforms = map[string]string{
+	"windows":  "Windows",
+	"macos":    "macOS",
+	"linux":    "Linux",
+	"android":  "Android",
+	"chromeos": "Chrome OS",
+}
+
+func osFamily(userAgent string) string {
+	switch {
+	case strings.Contains(userAgent, "iPhone") || strings.Contains(userAgent, "iPad"):
+		return "ios"
+	case strings.Contains(userAgent, "Android"):
+		return "android"
+	case strings.Contains(userAgent, "Windows"):
+		return "windows"
+	case strings.Contains(userAgent, "Macintosh"):
+		return "macos"
+	case strings.Contains(userAgent, "CrOS"):
+		return "chromeos"
+	case strings.Contains(userAgent, "Linux") || strings.Contains(userAgent, "X11"):
+		return "linux"
+	}
+	return ""
+}
+
+func (v *validator) checkPlatform() {
+	platform := v.fp.Navigator.Platform
+	var ok bool
+	switch v.os {
+	case "windows":
+		ok = platform == "Win32"
+	case "macos":
+		ok = platform == "MacIntel"
+	case "linux", "android", "chromeos":
+		ok = strings.HasPrefix(platform, "Linux")
+	case "ios":
+		ok = platform == "iPhone" || platform == "iPad" || platform == "MacIntel"
+	default:
+		return
+	}
+	if !ok {
+		v.report(CheckPlatform, SeverityError, []string{"navigator.platform", "navigator.userAgent"},
+			"platform %q does not match %s", platform, v.os)
+	}
+
+	if v.fp.Navigator.Oscpu == nil || v.engine() != "firefox" {
+		return
+	}
+	oscpu := *v.fp.Navigator.Oscpu
+	ok = true
+	switch v.os {
+	case "windows":
+		ok = strings.HasPrefix(oscpu, "Windows NT")
+	case "macos":
+		ok = strings.HasPrefix(oscpu, "Intel Mac OS X")
+	case "linux", "android":
+		ok = strings.HasPrefix(oscpu, "Linux")
+	}
+	if !ok {
+		v.report(CheckPlatform, SeverityError, []string{"navigator.oscpu", "navigator.userAgent"},
+			"oscpu %q does not match %s", oscpu, v.os)
+	}
+}
+
+// browserVendors are the navigator.vendor values per engine.
+var browserVendors = map[string]string{
+	"chrome":  "Google Inc.",
+	"edge":    "Google Inc.",
+	"safari":  "Apple Computer, Inc.",
+	"firefox": "",
+}
+
+func (v *validator) checkBrowserFields() {
+	nav := v.fp.Navigator
+	engine := v.engine()
+	if engine == "" {
+		return
+	}
+	if nav.Oscpu != nil && engine != "firefox" {
+		v.report(CheckBrowserFields, SeverityError, []string{"navigator.oscpu"}, "oscpu is only defined by desktop and Android Firefox")
+	}
+	wantProductSub := "20030107"
+	if engine == "firefox" {
+		wantProductSub = "20100101"
+	}
+	if nav.ProductSub != "" && nav.ProductSub != wantProductSub {
+		v.report(CheckBrowserFields, SeverityError, []string{"navigator.productSub"},
+			"productSub %q is not %s's %q", nav.ProductSub, engine, wantProductSub)
+	}
+	if want := browserVendors[engine]; nav.Vendor != want {
+		v.report(CheckBrowserFields, SeverityError, []string{"navigator.vendor"},
+			"vendor %q is not %s's %q", nav.Vendor, engine, want)
+	}
+}
+
+func (v *validator) checkScreen() {
+	s := v.fp.Screen
+	if s.AvailWidth > s.Width || s.AvailHeight > s.Height {
+		v.report(CheckScreen, SeverityError, []string{"screen.availWidth", "screen.availHeight", "screen.width", "screen.height"},
+			"available area %dx%d exceeds the screen %dx%d", s.AvailWidth, s.AvailHeight, s.Width, s.Height)
+	}
+	if s.InnerWidth > s.OuterWidth || s.InnerHeight > s.OuterHeight {
+		v.report(CheckScreen, SeverityError, []string{"screen.innerWidth", "screen.innerHeight", "screen.outerWidth", "screen.outerHeight"},
+			"viewport %dx%d exceeds the window %dx%d", s.InnerWidth, s.InnerHeight, s.OuterWidth, s.OuterHeight)
+	}
+	if s.ColorDepth != 0 && s.PixelDepth != 0 && s.ColorDepth != s.PixelDepth {
+		v.report(CheckScreen, SeverityWarning, []string{"screen.colorDepth", "screen.pixelDepth"},
+			"colorDepth %d differs from pixelDepth %d", s.ColorDepth, s.PixelDepth)
+	}
+}
+
+func (v *validator) checkDevice() {
+	dpr := v.fp.Screen.DevicePixelRatio
+	if v.mobile() {
+		if dpr != 0 && dpr < 1.5 {
+			v.report(CheckDevice, SeverityWarning, []string{"screen.devicePixelRatio"},
+				"devicePixelRatio %g is low for a %s phone or tablet", dpr, v.os)
+		}
+		if v.fp.Navigator.MaxTouchPoints == 0 {
+			v.report(CheckDevice, SeverityError, []string{"navigator.maxTouchPoints"}, "%s devices have a touch screen", v.os)
+		}
+	} else if v.os != "" && dpr > 3 {
+		v.report(CheckDevice, SeverityWarning, []string{"screen.devicePixelRatio"},
+			"devicePixelRatio %g is unusual for a %s desktop", dpr, v.os)
+	}
+	// Android tablets report mobile false, so only a mobile desktop is wrong.
+	if uadMobile, ok := v.fp.Navigator.UserAgentData["mobile"].(bool); ok && v.chromium() && uadMobile && v.os != "android" {
+		v.report(CheckDevice, SeverityError, []string{"navigator.userAgentData.mobile", "navigator.userAgent"},
+			"userAgentData mobile %t does not match %s", uadMobile, v.os)
+	}
+}
+
+func (v *validator) checkAcceptLanguage() {
+	header, ok := v.fp.Headers.Lookup("Accept-Language")
+	languages := v.fp.Navigator.Languages
+	if !ok || len(languages) == 0 {
+		return
+	}
+	var tags []string
+	for _, part := range strings.Split(header, ",") {
+		tag, _, _ := strings.Cut(part, ";")
+		tags = append(tags, strings.TrimSpace(tag))
+	}
+	if !strings.EqualFold(tags[0], languages[0]) {
+		v.report(CheckAcceptLanguage, SeverityError, []string{"headers.Accept-Language", "navigator.languages"},
+			"Accept-Language starts with %q but navigator.languages with %q", tags[0], languages[0])
+		return
+	}
+	for _, lang := range languages {
+		found := false
+		for _, tag := range tags {
+			if strings.EqualFold(tag, lang) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.report(CheckAcceptLanguage, SeverityError, []string{"headers.Accept-Language", "navigator.languages"},
+				"%q is in navigator.languages but not in Accept-Language", lang)
+		}
+	}
+	if v.fp.Navigator.Language != "" && v.fp.Navigator.Language != languages[0] {
+		v.report(CheckAcceptLanguage, SeverityError, []string{"navigator.language", "navigator.languages"},
+			"navigator.language %q is not the first of navigator.languages", v.fp.Navigator.Language)
+	}
+}
+
+// gpuOSMarkers are renderer substrings that only occur on some systems.
+var gpuOSMarkers = []struct {
+	marker string
+	os     []string
+}{
+	{"Direct3D", []string{"windows"}},
+	{"D3D11", []string{"windows"}},
+	{"Metal Renderer", []string{"macos", "ios"}},
+	{"Apple M", []string{"macos", "ios"}},
+	{"Apple GPU", []string{"macos", "ios"}},
+	{"Mesa", []string{"linux", "android", "chromeos"}},
+	{"llvmpipe", []string{"linux", "chromeos"}},
+	{"Adreno", []string{"android"}},
+	{"Mali", []string{"android", "linux", "chromeos"}},
+	{"PowerVR", []string{"android", "ios"}},
+}
+
+func (v *validator) checkGPU() {
+	card := v.fp.VideoCard
+	if card == nil || v.os == "" {
+		return
+	}
+	for _, m := range gpuOSMarkers {
+		if !strings.Contains(card.Renderer, m.marker) {
+			continue
+		}
+		ok := false
+		for _, os := range m.os {
+			if os == v.os {
+				ok = true
+			}
+		}
+		if !ok {
+			v.report(CheckGPU, SeverityError, []string{"videoCard.renderer", "navigator.userAgent"},
+				"renderer %q does not occur on %s", card.Renderer, v.os)
+		}
+	}
+	if (v.os == "macos" || v.os == "ios") && card.Vendor != "" &&
+		!strings.Contains(card.Vendor, "Apple") && !strings.Contains(card.Vendor, "Intel") &&
+		!strings.Contains(card.Vendor, "AMD") && !strings.Contains(card.Vendor, "ATI") {
+		v.report(CheckGPU, SeverityWarning, []string{"videoCard.vendor", "navigator.userAgent"},
+			"GPU vendor %q is unusual on %s", card.Vendor, v.os)
+	}
+}
@@ -0,0 +1,68 @@
+package fingerprint
+
+import "testing"
+
+func TestValidateNil(t *testing.T) {
+	findings := Validate(nil)
+	if len(findings) != 1 || findings[0].Check != CheckFingerprint || findings[0].Severity != SeverityError {
+		t.Errorf("Validate(nil) = %v, want one %s error", findings, CheckFingerprint)
+	}
+}
+
+func TestValidateGenerated(t *testing.T) {
+	g := newTestGenerator(t, WithSeed(8))
+	for i := 0; i < 500; i++ {
+		fp, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		for _, f := range Validate(fp) {
+			if f.Severity == SeverityError {
+				t.Errorf("sample %d: %v", i, f)
+			}
+		}
+	}
+}
+
+func TestValidateFindings(t *testing.T) {
+	g := newTestGenerator(t, WithSeed(9), WithBrowser("chrome"), WithOperatingSystem("windows"))
+	for _, tc := range []struct {
+		name     string
+		modify   func(fp *Fingerprint)
+		check    string
+		severity Severity
+	}{
+		{"platform", func(fp *Fingerprint) { fp.Navigator.Platform = "MacIntel" }, CheckPlatform, SeverityError},
+		{"oscpu", func(fp *Fingerprint) {
+			oscpu := "Windows NT 10.0; Win64; x64"
+			fp.Navigator.Oscpu = &oscpu
+		}, CheckBrowserFields, SeverityError},
+		{"vendor", func(fp *Fingerprint) { fp.Navigator.Vendor = "" }, CheckBrowserFields, SeverityError},
+		{"screen", func(fp *Fingerprint) { fp.Screen.AvailWidth = fp.Screen.Width + 1 }, CheckScreen, SeverityError},
+		{"languages", func(fp *Fingerprint) {
+			fp.Headers.Set("Accept-Language", "fr-FR,fr;q=0.9")
+			fp.Navigator.Languages = []string{"de-DE"}
+		}, CheckAcceptLanguage, SeverityError},
+		{"gpu", func(fp *Fingerprint) {
+			fp.VideoCard = &VideoCard{Vendor: "Apple", Renderer: "Apple M1"}
+		}, CheckGPU, SeverityError},
+		{"client hints", func(fp *Fingerprint) {
+			fp.Headers.Set("sec-ch-ua", `"Google Chrome";v="1", "Chromium";v="1"`)
+		}, CheckClientHints, SeverityWarning},
+	} {
+		fp, err := g.Generate()
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		tc.modify(fp)
+		found := false
+		for _, f := range Validate(fp) {
+			if f.Check == tc.check && f.Severity == tc.severity {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: findings %v lack a %s %s", tc.name, Validate(fp), tc.check, tc.severity)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package bayesian
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/data"
+)
+
+var (
+	// ErrCorruptNetwork is wrapped by errors for network definitions that
+	// cannot be decoded or fail Validate. It wraps data.ErrCorruptFile.
+	ErrCorruptNetwork = fmt.Errorf("corrupt network: %w", data.ErrCorruptFile)
+	// ErrSamplingFailed is wrapped by errors for a sample that ran into a
+	// table without probability mass. Another sample may succeed.
+	ErrSamplingFailed = errors.New("sampling failed")
+)
+
+// ErrConstraintUnsatisfiable is returned, possibly wrapped in a
+// *ConstraintError, when no sample of a network can satisfy the values it was
+// asked to respect.
+var ErrConstraintUnsatisfiable = errors.New("constraint unsatisfiable")
+
+// ConstraintError names the node and value that made sampling impossible.
+type ConstraintError struct {
+	Node  string
+	Value string
+}
+
+func (e *ConstraintError) Error() string {
+	return fmt.Sprintf("%v: no sample has %s = %q", ErrConstraintUnsatisfiable, e.Node, e.Value)
+}
+
+func (e *ConstraintError) Unwrap() error {
+	return ErrConstraintUnsatisfiable
+}
@@ -0,0 +1,115 @@
+package bayesian
+
+// Joint returns the joint distribution of the named nodes and their
+// ancestors, for iterating with Each. An unknown node gives a
+// *ConstraintError.
+func (bn *BayesianNetwork) Joint(nodes ...string) (*Posterior, error) {
+	targets := make([]*BayesianNode, 0, len(nodes))
+	for _, name := range nodes {
+		node := bn.nodesByName[name]
+		if node == nil {
+			return nil, &ConstraintError{Node: name}
+		}
+		targets = append(targets, node)
+	}
+	return bn.condition(nil, targets...)
+}
+
+// Each calls fn for every assignment of the enumerated nodes with positive
+// probability. The values map is reused between calls.
+func (p *Posterior) Each(fn func(values map[string]string, probability float64)) {
+	values := make(map[string]string, len(p.relevant))
+	prev := 0.0
+	for i, cum := range p.cum {
+		row := p.rows[i*len(p.relevant) : (i+1)*len(p.relevant)]
+		for j, node := range p.relevant {
+			values[node.Name()] = node.values[row[j]]
+		}
+		fn(values, cum-prev)
+		prev = cum
+	}
+}
+
+// Likelihood returns the probability of the observed values given the
+// values in given. Nodes listing several values contribute the sum of their
+// probabilities. Ancestors of the observed nodes that are neither given nor
+// observed are summed over, so observations may leave out nodes between
+// them and the given ones. Given values are set rather than conditioned on:
+// ancestors of a given node that are summed over keep their own
+// probabilities. Values a node never takes, and observations of nodes the
+// network does not have, give 0.
+func (bn *BayesianNetwork) Likelihood(given map[string]string, observed map[string][]string) float64 {
+	allowed := make(map[*BayesianNode]map[int]bool, len(observed))
+	for name, values := range observed {
+		node := bn.nodesByName[name]
+		if node == nil {
+			return 0
+		}
+		set := make(map[int]bool, len(values))
+		for _, v := range values {
+			if idx, ok := node.index[v]; ok {
+				set[idx] = true
+			}
+		}
+		if len(set) == 0 {
+			return 0
+		}
+		allowed[node] = set
+	}
+
+	// The observed nodes and their ancestors up to the given ones.
+	marked := make(map[*BayesianNode]bool)
+	var visit func(n *BayesianNode)
+	visit = func(n *BayesianNode) {
+		if _, fixed := given[n.Name()]; marked[n] || fixed && allowed[n] == nil {
+			return
+		}
+		marked[n] = true
+		for _, parent := range n.ParentNames() {
+			if pn := bn.nodesByName[parent]; pn != nil {
+				visit(pn)
+			}
+		}
+	}
+	for n := range allowed {
+		visit(n)
+	}
+	relevant := make([]*BayesianNode, 0, len(marked))
+	for _, n := range bn.nodesInOrder {
+		if marked[n] {
+			relevant = append(relevant, n)
+		}
+	}
+
+	values := make(map[string]string, len(given)+len(relevant))
+	for name, v := range given {
+		values[name] = v
+	}
+	var sum func(depth int) float64
+	sum = func(depth int) float64 {
+		if depth == len(relevant) {
+			return 1
+		}
+		node := relevant[depth]
+		dist := node.cpt.lookup(node.def.ParentNames, values)
+		if dist == nil || dist.total() <= 0 {
+			return 0
+		}
+		set := allowed[node]
+		total := 0.0
+		for i, v := range dist.values {
+			if set != nil && !set[v] {
+				continue
+			}
+			values[node.Name()] = node.values[v]
+			total += dist.weightAt(i) * sum(depth+1)
+		}
+		if v, ok := given[node.Name()]; ok {
+			values[node.Name()] = v
+		} else {
+			delete(values, node.Name())
+		}
+		return total / dist.total()
+	}
+	return sum(0)
+}
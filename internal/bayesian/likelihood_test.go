@@ -0,0 +1,40 @@
+package bayesian
+
+import (
+	"math"
+	"testing"
+)
+
+func TestLikelihood(t *testing.T) {
+	bn := newTestNetwork(t, weatherJSON)
+	for _, tc := range []struct {
+		given    map[string]string
+		observed map[string][]string
+		want     float64
+	}{
+		// .01*.99 + .99*.8, summing over Sprinkler.
+		{map[string]string{"Rain": "yes"}, map[string][]string{"Grass": {"wet"}}, .8019},
+		{map[string]string{"Rain": "no"}, map[string][]string{"Sprinkler": {"on"}, "Grass": {"wet"}}, .4 * .9},
+		{nil, map[string][]string{"Grass": {"wet"}}, .44838},
+		{nil, map[string][]string{"Grass": {"wet", "dry"}}, 1},
+		{map[string]string{"Rain": "no"}, map[string][]string{"Sprinkler": {"on", "off"}, "Grass": {"dry"}}, .4*.1 + .6},
+		// Rain keeps its prior rather than the posterior given Sprinkler=off.
+		{map[string]string{"Sprinkler": "off"}, map[string][]string{"Grass": {"wet"}}, .2 * .8},
+		{map[string]string{"Rain": "yes"}, nil, 1},
+		{map[string]string{"Rain": "yes"}, map[string][]string{"Grass": {"soaked"}}, 0},
+		{map[string]string{"Rain": "yes"}, map[string][]string{"Hail": {"yes"}}, 0},
+	} {
+		if got := bn.Likelihood(tc.given, tc.observed); math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("Likelihood(%v, %v) = %v, want %v", tc.given, tc.observed, got, tc.want)
+		}
+	}
+}
+
+func TestLikelihoodKeepsGiven(t *testing.T) {
+	bn := newTestNetwork(t, weatherJSON)
+	given := map[string]string{"Rain": "no"}
+	bn.Likelihood(given, map[string][]string{"Grass": {"wet"}})
+	if len(given) != 1 || given["Rain"] != "no" {
+		t.Errorf("given changed to %v", given)
+	}
+}
@@ -3,6 +3,9 @@ package bayesian
 import (
 	"encoding/json"
 	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
 
 	"github.com/yourneighborhoodchef/browserforge/internal/data"
 )
@@ -11,47 +14,104 @@ type networkDefinition struct {
 	Nodes []nodeDefinition `json:"nodes"`
 }
 
+// BayesianNetwork is immutable once loaded and may be shared between
+// goroutines and generators.
 type BayesianNetwork struct {
 	nodesInOrder []*BayesianNode
 	nodesByName  map[string]*BayesianNode
 }
 
-func LoadInputNetwork() (*BayesianNetwork, error) {
-	return loadNetwork(data.InputNetwork)
+type lazyNetwork struct {
+	once sync.Once
+	bn   *BayesianNetwork
+	err  error
 }
 
-func LoadHeaderNetwork() (*BayesianNetwork, error) {
-	return loadNetwork(data.HeaderNetwork)
+// embeddedNetworks holds the networks parsed from the embedded data. They are
+// loaded on first use and shared by every generator in the process.
+var embeddedNetworks = map[string]*lazyNetwork{
+	data.InputNetworkFile:       {},
+	data.HeaderNetworkFile:      {},
+	data.FingerprintNetworkFile: {},
 }
 
-func LoadFingerprintNetwork() (*BayesianNetwork, error) {
-	return loadNetwork(data.FingerprintNetwork)
+func LoadInputNetwork(src data.Source) (*BayesianNetwork, error) {
+	return loadNetworkFile(src, data.InputNetworkFile)
 }
 
+func LoadHeaderNetwork(src data.Source) (*BayesianNetwork, error) {
+	return loadNetworkFile(src, data.HeaderNetworkFile)
+}
+
+func LoadFingerprintNetwork(src data.Source) (*BayesianNetwork, error) {
+	return loadNetworkFile(src, data.FingerprintNetworkFile)
+}
+
+func loadNetworkFile(src data.Source, name string) (*BayesianNetwork, error) {
+	if lazy, ok := embeddedNetworks[name]; ok && src == data.Embedded {
+		lazy.once.Do(func() {
+			lazy.bn, lazy.err = readNetwork(src, name)
+		})
+		return lazy.bn, lazy.err
+	}
+	return readNetwork(src, name)
+}
+
+func readNetwork(src data.Source, name string) (*BayesianNetwork, error) {
+	raw, err := data.Read(src, name)
+	if err != nil {
+		return nil, err
+	}
+	return loadNetwork(raw)
+}
+
+// loadNetwork parses a network and rejects it unless it validates.
 func loadNetwork(raw []byte) (*BayesianNetwork, error) {
+	bn, err := ParseNetwork(raw)
+	if err != nil {
+		return nil, err
+	}
+	if problems := bn.Validate(); len(problems) > 0 {
+		if len(problems) > 1 {
+			return nil, fmt.Errorf("%w: %v (and %d more problems)", ErrCorruptNetwork, problems[0], len(problems)-1)
+		}
+		return nil, fmt.Errorf("%w: %v", ErrCorruptNetwork, problems[0])
+	}
+	return bn, nil
+}
+
+// ParseNetwork decodes a network definition without validating its
+// structure, so that Validate can report every problem it has.
+func ParseNetwork(raw []byte) (*BayesianNetwork, error) {
 	var def networkDefinition
 	if err := json.Unmarshal(raw, &def); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal network: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrCorruptNetwork, err)
 	}
 	bn := &BayesianNetwork{
 		nodesByName: make(map[string]*BayesianNode, len(def.Nodes)),
 	}
 	for _, nd := range def.Nodes {
-		node := &BayesianNode{def: nd}
+		node, err := newNode(nd)
+		if err != nil {
+			return nil, err
+		}
 		bn.nodesInOrder = append(bn.nodesInOrder, node)
 		bn.nodesByName[node.Name()] = node
 	}
 	return bn, nil
 }
 
-func (bn *BayesianNetwork) GenerateSample(inputValues map[string]string) (map[string]string, error) {
-	sample := make(map[string]string)
+// GenerateSample forward-samples the nodes missing from inputValues. Preset
+// values are taken as given and do not influence the nodes above them; use
+// Condition for that.
+func (bn *BayesianNetwork) GenerateSample(rng *rand.Rand, inputValues map[string]string) (map[string]string, error) {
+	sample := make(map[string]string, len(bn.nodesInOrder)+len(inputValues))
 	for k, v := range inputValues {
 		sample[k] = v
 	}
 	for _, node := range bn.nodesInOrder {
 		if _, exists := sample[node.Name()]; !exists {
-			val, err := node.Sample(sample)
+			val, err := node.Sample(rng, sample)
 			if err != nil {
 				return nil, err
 			}
@@ -60,3 +120,34 @@ func (bn *BayesianNetwork) GenerateSample(inputValues map[string]string) (map[st
 	}
 	return sample, nil
 }
+
+// CheckValues reports, as a *ConstraintError, the first of values that names
+// a node the network does not have or a value the node never takes.
+// GenerateSample accepts such values silently and samples their children from
+// the fallback branch of each table.
+func (bn *BayesianNetwork) CheckValues(values map[string]string) error {
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		node := bn.nodesByName[name]
+		if node == nil {
+			return &ConstraintError{Node: name, Value: values[name]}
+		}
+		if _, ok := node.index[values[name]]; !ok {
+			return &ConstraintError{Node: name, Value: values[name]}
+		}
+	}
+	return nil
+}
+
+func (bn *BayesianNetwork) Node(name string) *BayesianNode {
+	return bn.nodesByName[name]
+}
+
+// Nodes returns the nodes in the order they are sampled.
+func (bn *BayesianNetwork) Nodes() []*BayesianNode {
+	return append([]*BayesianNode(nil), bn.nodesInOrder...)
+}
@@ -0,0 +1,89 @@
+package bayesian
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/data"
+)
+
+// weatherJSON is a small network whose probabilities are easy to work out by
+// hand. Grass has no branch for Sprinkler=off and Rain=no other than the
+// skip branch, which only ever gives dry.
+const weatherJSON = `{"nodes": [
+	{"name": "Rain", "parentNames": [], "possibleValues": ["yes", "no"],
+	 "conditionalProbabilities": {"yes": 0.2, "no": 0.8}},
+	{"name": "Sprinkler", "parentNames": ["Rain"], "possibleValues": ["on", "off"],
+	 "conditionalProbabilities": {"deeper": {
+		"yes": {"on": 0.01, "off": 0.99},
+		"no": {"on": 0.4, "off": 0.6}}}},
+	{"name": "Grass", "parentNames": ["Sprinkler", "Rain"], "possibleValues": ["wet", "dry"],
+	 "conditionalProbabilities": {"deeper": {
+		"on": {"deeper": {"yes": {"wet": 0.99, "dry": 0.01}, "no": {"wet": 0.9, "dry": 0.1}}},
+		"off": {"deeper": {"yes": {"wet": 0.8, "dry": 0.2}}, "skip": {"dry": 1}}}}}
+]}`
+
+func newTestNetwork(t testing.TB, raw string) *BayesianNetwork {
+	t.Helper()
+	bn, err := loadNetwork([]byte(raw))
+	if err != nil {
+		t.Fatalf("loadNetwork: %v", err)
+	}
+	return bn
+}
+
+func TestGenerateSampleFrequencies(t *testing.T) {
+	bn := newTestNetwork(t, weatherJSON)
+	rng := rand.New(rand.NewSource(1))
+	const n = 100000
+	wet := 0
+	for i := 0; i < n; i++ {
+		sample, err := bn.GenerateSample(rng, nil)
+		if err != nil {
+			t.Fatalf("GenerateSample: %v", err)
+		}
+		if sample["Grass"] == "wet" {
+			wet++
+		}
+	}
+	// P(wet) = .2(.01*.99 + .99*.8) + .8(.4*.9 + .6*0) = .44838
+	if got := float64(wet) / n; got < 0.443 || got > 0.454 {
+		t.Errorf("P(Grass=wet) = %.4f, want about 0.4484", got)
+	}
+}
+
+func benchmarkGenerateSample(b *testing.B, load func(data.Source) (*BayesianNetwork, error)) {
+	bn, err := load(data.Embedded)
+	if err != nil {
+		b.Fatal(err)
+	}
+	rng := rand.New(rand.NewSource(1))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := bn.GenerateSample(rng, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenerateSampleInput(b *testing.B) {
+	benchmarkGenerateSample(b, LoadInputNetwork)
+}
+
+func BenchmarkGenerateSampleHeader(b *testing.B) {
+	benchmarkGenerateSample(b, LoadHeaderNetwork)
+}
+
+func BenchmarkNodeSample(b *testing.B) {
+	bn := newTestNetwork(b, weatherJSON)
+	node := bn.Node("Grass")
+	parents := map[string]string{"Sprinkler": "on", "Rain": "no"}
+	rng := rand.New(rand.NewSource(1))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := node.Sample(rng, parents); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -1,19 +1,36 @@
 package bayesian
 
 import (
-	"errors"
 	"fmt"
 	"math/rand"
+	"sort"
 )
 
 type nodeDefinition struct {
 	Name                     string                 `json:"name"`
 	ParentNames              []string               `json:"parentNames"`
+	PossibleValues           []string               `json:"possibleValues"`
 	ConditionalProbabilities map[string]interface{} `json:"conditionalProbabilities"`
 }
 
 type BayesianNode struct {
-	def nodeDefinition
+	def    nodeDefinition
+	values []string
+	index  map[string]int
+	cpt    *cptNode
+	// defects are the flaws of the definition that compiling hides, for
+	// Validate to report.
+	defects []string
+}
+
+func newNode(def nodeDefinition) (*BayesianNode, error) {
+	n := &BayesianNode{def: def}
+	if err := n.compile(); err != nil {
+		return nil, fmt.Errorf("%w: node %s: %w", ErrCorruptNetwork, def.Name, err)
+	}
+
+	n.def.ConditionalProbabilities = nil
+	return n, nil
 }
 
 func (n *BayesianNode) Name() string {
@@ -24,71 +41,215 @@ func (n *BayesianNode) ParentNames() []string {
 	return n.def.ParentNames
 }
 
-func (n *BayesianNode) Sample(parentValues map[string]string) (string, error) {
-	probs, err := n.probabilitiesGiven(parentValues)
-	if err != nil {
-		return "", err
+func (n *BayesianNode) PossibleValues() []string {
+	return n.values
+}
+
+func (n *BayesianNode) Sample(rng *rand.Rand, parentValues map[string]string) (string, error) {
+	dist := n.cpt.lookup(n.def.ParentNames, parentValues)
+	if dist == nil || dist.total() <= 0 {
+		return "", fmt.Errorf("%w: node %s: total probability is zero", ErrSamplingFailed, n.Name())
 	}
+	return n.values[dist.sample(rng)], nil
+}
 
-	var values []string
-	var weights []float64
-	for v, w := range probs {
-		values = append(values, v)
-		weights = append(weights, w)
+// cptNode is one level of a compiled conditional probability table. Inner
+// levels branch on the value of the parent at that depth, falling back to
+// skip when the value has no dedicated branch; leaves hold a distribution.
+type cptNode struct {
+	deeper map[string]*cptNode
+	skip   *cptNode
+	leaf   *distribution
+}
+
+// lookup returns the distribution for parentValues, or nil when a level has
+// neither a branch for the parent's value nor a skip branch. The uncompiled
+// tables stayed at that level instead, which left the leaf holding branches
+// rather than weights and failed all the same; nil makes Sample report
+// ErrSamplingFailed directly.
+func (c *cptNode) lookup(parents []string, parentValues map[string]string) *distribution {
+	for _, parent := range parents {
+		if c == nil {
+			return nil
+		}
+		if val, exists := parentValues[parent]; exists {
+			if next, found := c.deeper[val]; found {
+				c = next
+				continue
+			}
+		}
+		c = c.skip
 	}
-	total := 0.0
-	for _, w := range weights {
-		total += w
+	if c == nil {
+		return nil
 	}
-	if total <= 0 {
-		return "", errors.New("total probability is zero")
+	return c.leaf
+}
+
+// distribution lists the values with a positive weight as indices into the
+// node's values, in ascending order, with their cumulative weights.
+type distribution struct {
+	values []int
+	cum    []float64
+}
+
+func (d *distribution) total() float64 {
+	if len(d.cum) == 0 {
+		return 0
+	}
+	return d.cum[len(d.cum)-1]
+}
+
+func (d *distribution) sample(rng *rand.Rand) int {
+	target := rng.Float64() * d.total()
+	i := sort.SearchFloat64s(d.cum, target)
+	if i == len(d.cum) {
+		i = len(d.cum) - 1
+	}
+	return d.values[i]
+}
+
+func (d *distribution) weight(value int) float64 {
+	i := sort.SearchInts(d.values, value)
+	if i == len(d.values) || d.values[i] != value {
+		return 0
+	}
+	return d.weightAt(i)
+}
+
+func (d *distribution) weightAt(i int) float64 {
+	if i == 0 {
+		return d.cum[0]
+	}
+	return d.cum[i] - d.cum[i-1]
+}
+
+func (n *BayesianNode) compile() error {
+	n.values = append([]string(nil), n.def.PossibleValues...)
+	n.index = make(map[string]int, len(n.values))
+	for i, v := range n.values {
+		if _, dup := n.index[v]; !dup {
+			n.index[v] = i
+		}
 	}
-	target := rand.Float64() * total
-	cum := 0.0
-	for i, w := range weights {
-		cum += w
-		if target <= cum {
-			return values[i], nil
+
+	var extra []string
+	collectLeafKeys(n.def.ConditionalProbabilities, len(n.def.ParentNames), func(k string) {
+		if _, ok := n.index[k]; !ok {
+			n.index[k] = -1
+			extra = append(extra, k)
 		}
+	})
+	sort.Strings(extra)
+	for _, v := range extra {
+		n.index[v] = len(n.values)
+		n.values = append(n.values, v)
+	}
+
+	cpt, err := n.compileLevel(n.def.ConditionalProbabilities, nil)
+	if err != nil {
+		return err
 	}
+	n.cpt = cpt
+	return nil
+}
 
-	return values[0], nil
+func collectLeafKeys(obj interface{}, depth int, add func(string)) {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if depth == 0 {
+		for k := range m {
+			add(k)
+		}
+		return
+	}
+	if deeper, ok := m["deeper"].(map[string]interface{}); ok {
+		for _, next := range deeper {
+			collectLeafKeys(next, depth-1, add)
+		}
+	}
+	collectLeafKeys(m["skip"], depth-1, add)
 }
 
-func (n *BayesianNode) probabilitiesGiven(parentValues map[string]string) (map[string]float64, error) {
-	var current interface{} = n.def.ConditionalProbabilities
-	for _, parent := range n.def.ParentNames {
-		m, ok := current.(map[string]interface{})
+// compileLevel compiles the level of a table reached by the parent values in
+// path.
+func (n *BayesianNode) compileLevel(obj interface{}, path []string) (*cptNode, error) {
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid type for conditional probabilities")
+	}
+	depth := len(path)
+	if depth == len(n.def.ParentNames) {
+		leaf, err := n.compileLeaf(m, path)
+		if err != nil {
+			return nil, err
+		}
+		return &cptNode{leaf: leaf}, nil
+	}
+
+	parent := n.def.ParentNames[depth]
+	for k := range m {
+		if k != "deeper" && k != "skip" {
+			n.defects = append(n.defects, fmt.Sprintf("table%s holds probabilities instead of branching on %s", pathSuffix(path), parent))
+			break
+		}
+	}
+	c := &cptNode{}
+	if raw, exists := m["deeper"]; exists {
+		deeper, ok := raw.(map[string]interface{})
 		if !ok {
 			return nil, fmt.Errorf("invalid type for conditional probabilities")
 		}
-		if val, exists := parentValues[parent]; exists {
-			if deeper, ok := m["deeper"].(map[string]interface{}); ok {
-				if next, found := deeper[val]; found {
-					current = next
-					continue
-				}
+		c.deeper = make(map[string]*cptNode, len(deeper))
+		for val, next := range deeper {
+			child, err := n.compileLevel(next, append(path, parent+"="+val))
+			if err != nil {
+				return nil, err
 			}
+			c.deeper[val] = child
 		}
-		if skip, ok := m["skip"].(map[string]interface{}); ok {
-			current = skip
-		}
-
 	}
-	m, ok := current.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("invalid final probabilities structure")
+	if raw, exists := m["skip"]; exists {
+		skip, err := n.compileLevel(raw, append(path, parent+"=*"))
+		if err != nil {
+			return nil, err
+		}
+		c.skip = skip
 	}
-	result := make(map[string]float64, len(m))
+	return c, nil
+}
+
+func (n *BayesianNode) compileLeaf(m map[string]interface{}, path []string) (*distribution, error) {
+	weights := make(map[int]float64, len(m))
 	for k, v := range m {
+		var w float64
 		switch x := v.(type) {
 		case float64:
-			result[k] = x
+			w = x
 		case int:
-			result[k] = float64(x)
+			w = float64(x)
 		default:
 			return nil, fmt.Errorf("unsupported probability type for %s: %T", k, v)
 		}
+		if w < 0 {
+			n.defects = append(n.defects, fmt.Sprintf("negative probability %g for %q%s", w, k, pathSuffix(path)))
+		}
+		if w > 0 {
+			weights[n.index[k]] = w
+		}
+	}
+
+	d := &distribution{
+		values: make([]int, 0, len(weights)),
+		cum:    make([]float64, 0, len(weights)),
+	}
+	for i := range n.values {
+		if w, ok := weights[i]; ok {
+			d.values = append(d.values, i)
+			d.cum = append(d.cum, d.total()+w)
+		}
 	}
-	return result, nil
+	return d, nil
 }
@@ -0,0 +1,38 @@
+package bayesian
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestLookupFallback(t *testing.T) {
+	bn := newTestNetwork(t, weatherJSON)
+	grass := bn.Node("Grass")
+	rng := rand.New(rand.NewSource(1))
+	for _, tc := range []struct {
+		parents map[string]string
+		want    string
+	}{
+		// Sprinkler=off, Rain=no has no branch of its own and takes skip.
+		{map[string]string{"Sprinkler": "off", "Rain": "no"}, "dry"},
+		// So does a value the table has never seen.
+		{map[string]string{"Sprinkler": "off", "Rain": "hail"}, "dry"},
+	} {
+		for i := 0; i < 20; i++ {
+			got, err := grass.Sample(rng, tc.parents)
+			if err != nil || got != tc.want {
+				t.Fatalf("Sample(%v) = %q, %v; want %q", tc.parents, got, err, tc.want)
+			}
+		}
+	}
+
+	// Sprinkler=broken has neither a branch nor a skip to fall back on.
+	_, err := grass.Sample(rng, map[string]string{"Sprinkler": "broken", "Rain": "yes"})
+	if !errors.Is(err, ErrSamplingFailed) {
+		t.Errorf("Sample without a branch: error = %v, want ErrSamplingFailed", err)
+	}
+	if dist := grass.cpt.lookup(grass.ParentNames(), map[string]string{"Sprinkler": "broken", "Rain": "yes"}); dist != nil {
+		t.Errorf("lookup without a branch = %v, want nil", dist)
+	}
+}
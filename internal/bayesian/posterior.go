@@ -0,0 +1,224 @@
+package bayesian
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// maxAssignments bounds the joint assignments Condition enumerates, so that
+// evidence deep in a wide network fails instead of exhausting memory.
+const maxAssignments = 1 << 21
+
+// Posterior is a network conditioned on evidence. The evidence nodes and
+// their ancestors are enumerated once, weighting every joint assignment by
+// its probability; sampling picks an assignment by weight and forward-samples
+// the remaining nodes, which the evidence does not affect given their
+// ancestors. Samples therefore follow the exact conditional distribution.
+//
+// A Posterior is immutable and may be shared between goroutines.
+type Posterior struct {
+	bn       *BayesianNetwork
+	relevant []*BayesianNode
+	// rows holds one value index per relevant node for each assignment with
+	// positive weight; cum holds the cumulative weights.
+	rows []int32
+	cum  []float64
+}
+
+// Condition returns the network conditioned on each evidence node taking one
+// of the listed values. Unlike GenerateSample, which only skips preset nodes,
+// evidence also shifts the distribution of the nodes above it.
+func (bn *BayesianNetwork) Condition(evidence map[string][]string) (*Posterior, error) {
+	p, err := bn.condition(evidence)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.cum) == 0 {
+		return nil, fmt.Errorf("%w: no sample satisfies the evidence on %s", ErrConstraintUnsatisfiable, strings.Join(sortedKeys(evidence), ", "))
+	}
+	return p, nil
+}
+
+// condition enumerates the evidence nodes, the extra nodes and their
+// ancestors. The result has no assignments when the evidence is impossible.
+func (bn *BayesianNetwork) condition(evidence map[string][]string, extra ...*BayesianNode) (*Posterior, error) {
+	allowed := make(map[*BayesianNode]map[int]bool, len(evidence))
+	for _, name := range sortedKeys(evidence) {
+		node := bn.nodesByName[name]
+		if node == nil {
+			return nil, &ConstraintError{Node: name, Value: strings.Join(evidence[name], "|")}
+		}
+		set := make(map[int]bool, len(evidence[name]))
+		for _, v := range evidence[name] {
+			if idx, ok := node.index[v]; ok {
+				set[idx] = true
+			}
+		}
+		if len(set) == 0 {
+			return nil, &ConstraintError{Node: name, Value: strings.Join(evidence[name], "|")}
+		}
+		allowed[node] = set
+	}
+
+	nodes := make([]*BayesianNode, 0, len(allowed)+len(extra))
+	for n := range allowed {
+		nodes = append(nodes, n)
+	}
+	nodes = append(nodes, extra...)
+	p := &Posterior{bn: bn, relevant: bn.ancestors(nodes)}
+	assignment := make([]int32, len(p.relevant))
+	values := make(map[string]string, len(p.relevant))
+	if err := p.enumerate(0, 1, assignment, values, allowed); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func sortedKeys(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ancestors returns the given nodes and all their ancestors in network order.
+func (bn *BayesianNetwork) ancestors(nodes []*BayesianNode) []*BayesianNode {
+	marked := make(map[*BayesianNode]bool)
+	var visit func(n *BayesianNode)
+	visit = func(n *BayesianNode) {
+		if marked[n] {
+			return
+		}
+		marked[n] = true
+		for _, parent := range n.ParentNames() {
+			if pn := bn.nodesByName[parent]; pn != nil {
+				visit(pn)
+			}
+		}
+	}
+	for _, n := range nodes {
+		visit(n)
+	}
+	var ordered []*BayesianNode
+	for _, n := range bn.nodesInOrder {
+		if marked[n] {
+			ordered = append(ordered, n)
+		}
+	}
+	return ordered
+}
+
+func (p *Posterior) enumerate(depth int, weight float64, assignment []int32, values map[string]string, allowed map[*BayesianNode]map[int]bool) error {
+	if depth == len(p.relevant) {
+		if len(p.cum) == maxAssignments {
+			return fmt.Errorf("evidence needs more than %d assignments to enumerate", maxAssignments)
+		}
+		p.rows = append(p.rows, assignment...)
+		total := 0.0
+		if len(p.cum) > 0 {
+			total = p.cum[len(p.cum)-1]
+		}
+		p.cum = append(p.cum, total+weight)
+		return nil
+	}
+	node := p.relevant[depth]
+	dist := node.cpt.lookup(node.def.ParentNames, values)
+	if dist == nil || dist.total() <= 0 {
+		return nil
+	}
+	total := dist.total()
+	set := allowed[node]
+	for i, v := range dist.values {
+		if set != nil && !set[v] {
+			continue
+		}
+		assignment[depth] = int32(v)
+		values[node.Name()] = node.values[v]
+		if err := p.enumerate(depth+1, weight*dist.weightAt(i)/total, assignment, values, allowed); err != nil {
+			return err
+		}
+	}
+	delete(values, node.Name())
+	return nil
+}
+
+// Probability returns the prior probability of the evidence.
+func (p *Posterior) Probability() float64 {
+	if len(p.cum) == 0 {
+		return 0
+	}
+	return p.cum[len(p.cum)-1]
+}
+
+// Sample draws one full sample of the network given the evidence.
+func (p *Posterior) Sample(rng *rand.Rand) (map[string]string, error) {
+	target := rng.Float64() * p.Probability()
+	i := sort.SearchFloat64s(p.cum, target)
+	if i == len(p.cum) {
+		i = len(p.cum) - 1
+	}
+	preset := make(map[string]string, len(p.relevant))
+	row := p.rows[i*len(p.relevant) : (i+1)*len(p.relevant)]
+	for j, node := range p.relevant {
+		preset[node.Name()] = node.values[row[j]]
+	}
+	return p.bn.GenerateSample(rng, preset)
+}
+
+// GenerateConditionalSample draws one sample given the evidence. Callers
+// sampling repeatedly with the same evidence should keep the Posterior from
+// Condition instead.
+func (bn *BayesianNetwork) GenerateConditionalSample(rng *rand.Rand, evidence map[string][]string) (map[string]string, error) {
+	p, err := bn.Condition(evidence)
+	if err != nil {
+		return nil, err
+	}
+	return p.Sample(rng)
+}
+
+// Probability returns the probability that a sample satisfies the evidence,
+// which is 0 for values the network never produces together.
+func (bn *BayesianNetwork) Probability(evidence map[string][]string) (float64, error) {
+	p, err := bn.condition(evidence)
+	if err != nil {
+		return 0, err
+	}
+	return p.Probability(), nil
+}
+
+// Marginal returns the distribution of node's values given the evidence,
+// leaving out values with probability zero. With no evidence it is the
+// share of samples taking each value. An unknown node gives a
+// *ConstraintError.
+func (bn *BayesianNetwork) Marginal(node string, evidence map[string][]string) (map[string]float64, error) {
+	target := bn.nodesByName[node]
+	if target == nil {
+		return nil, &ConstraintError{Node: node}
+	}
+	p, err := bn.condition(evidence, target)
+	if err != nil {
+		return nil, err
+	}
+	if len(p.cum) == 0 {
+		return nil, fmt.Errorf("%w: no sample satisfies the evidence on %s", ErrConstraintUnsatisfiable, strings.Join(sortedKeys(evidence), ", "))
+	}
+	col := 0
+	for i, n := range p.relevant {
+		if n == target {
+			col = i
+		}
+	}
+	total := p.Probability()
+	dist := make(map[string]float64)
+	prev := 0.0
+	for i, cum := range p.cum {
+		value := target.values[p.rows[i*len(p.relevant)+col]]
+		dist[value] += (cum - prev) / total
+		prev = cum
+	}
+	return dist, nil
+}
@@ -0,0 +1,102 @@
+package bayesian
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestProbability(t *testing.T) {
+	bn := newTestNetwork(t, weatherJSON)
+	for _, tc := range []struct {
+		evidence map[string][]string
+		want     float64
+	}{
+		{nil, 1},
+		{map[string][]string{"Grass": {"wet"}}, .44838},
+		{map[string][]string{"Grass": {"wet", "dry"}}, 1},
+		// .2 * (.01*.99 + .99*.8)
+		{map[string][]string{"Rain": {"yes"}, "Grass": {"wet"}}, .16038},
+		{map[string][]string{"Rain": {"no"}, "Sprinkler": {"off"}, "Grass": {"wet"}}, 0},
+	} {
+		got, err := bn.Probability(tc.evidence)
+		if err != nil {
+			t.Fatalf("Probability(%v): %v", tc.evidence, err)
+		}
+		if math.Abs(got-tc.want) > 1e-12 {
+			t.Errorf("Probability(%v) = %v, want %v", tc.evidence, got, tc.want)
+		}
+	}
+
+	var constraintErr *ConstraintError
+	if _, err := bn.Probability(map[string][]string{"Grass": {"soaked"}}); !errors.As(err, &constraintErr) || constraintErr.Node != "Grass" {
+		t.Errorf("Probability of an unknown value: error = %v, want a *ConstraintError for Grass", err)
+	}
+}
+
+func TestMarginal(t *testing.T) {
+	bn := newTestNetwork(t, weatherJSON)
+	for _, tc := range []struct {
+		node     string
+		evidence map[string][]string
+		want     map[string]float64
+	}{
+		{"Grass", nil, map[string]float64{"wet": .44838, "dry": .55162}},
+		{"Sprinkler", map[string][]string{"Rain": {"no"}}, map[string]float64{"on": .4, "off": .6}},
+		// Bayes' rule: .16038 and .8*.4*.9 over P(wet).
+		{"Rain", map[string][]string{"Grass": {"wet"}}, map[string]float64{"yes": .16038 / .44838, "no": .288 / .44838}},
+		// Rain=no with the sprinkler off never leaves the grass wet.
+		{"Sprinkler", map[string][]string{"Rain": {"no"}, "Grass": {"wet"}}, map[string]float64{"on": 1}},
+	} {
+		got, err := bn.Marginal(tc.node, tc.evidence)
+		if err != nil {
+			t.Fatalf("Marginal(%s, %v): %v", tc.node, tc.evidence, err)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("Marginal(%s, %v) = %v, want %v", tc.node, tc.evidence, got, tc.want)
+			continue
+		}
+		for v, p := range tc.want {
+			if math.Abs(got[v]-p) > 1e-12 {
+				t.Errorf("Marginal(%s, %v) = %v, want %v", tc.node, tc.evidence, got, tc.want)
+				break
+			}
+		}
+	}
+
+	var constraintErr *ConstraintError
+	if _, err := bn.Marginal("Hail", nil); !errors.As(err, &constraintErr) || constraintErr.Node != "Hail" {
+		t.Errorf("Marginal of an unknown node: error = %v, want a *ConstraintError for Hail", err)
+	}
+	impossible := map[string][]string{"Rain": {"no"}, "Sprinkler": {"off"}, "Grass": {"wet"}}
+	if _, err := bn.Marginal("Rain", impossible); !errors.Is(err, ErrConstraintUnsatisfiable) {
+		t.Errorf("Marginal given impossible evidence: error = %v, want ErrConstraintUnsatisfiable", err)
+	}
+}
+
+func TestJoint(t *testing.T) {
+	bn := newTestNetwork(t, weatherJSON)
+	p, err := bn.Joint("Sprinkler")
+	if err != nil {
+		t.Fatalf("Joint: %v", err)
+	}
+	want := map[string]float64{
+		"yes/on": .2 * .01, "yes/off": .2 * .99,
+		"no/on": .8 * .4, "no/off": .8 * .6,
+	}
+	got := make(map[string]float64)
+	p.Each(func(values map[string]string, probability float64) {
+		got[values["Rain"]+"/"+values["Sprinkler"]] += probability
+	})
+	for k, w := range want {
+		if math.Abs(got[k]-w) > 1e-12 {
+			t.Errorf("Joint(Sprinkler) = %v, want %v", got, want)
+			break
+		}
+	}
+
+	var constraintErr *ConstraintError
+	if _, err := bn.Joint("Sprinkler", "Hail"); !errors.As(err, &constraintErr) || constraintErr.Node != "Hail" {
+		t.Errorf("Joint with an unknown node: error = %v, want a *ConstraintError for Hail", err)
+	}
+}
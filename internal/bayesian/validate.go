@@ -0,0 +1,118 @@
+package bayesian
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// normalizationTolerance is how far the probabilities of a table may sum from
+// 1 before Validate reports it. Trained networks are rounded when written.
+const normalizationTolerance = 1e-3
+
+// Problem is a defect in a network definition.
+type Problem struct {
+	Node    string
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Node == "" {
+		return p.Message
+	}
+	return fmt.Sprintf("node %s: %s", p.Node, p.Message)
+}
+
+// Validate checks the structure of the network: that node names are unique,
+// that every parent exists and precedes its children, that each conditional
+// probability table branches on the node's parents and their values, and that
+// every table holds only the node's values with non-negative probabilities
+// summing to 1. It returns nil for a sound network.
+func (bn *BayesianNetwork) Validate() []Problem {
+	var problems []Problem
+	position := make(map[string]int, len(bn.nodesInOrder))
+	for i, node := range bn.nodesInOrder {
+		if _, dup := position[node.Name()]; dup {
+			problems = append(problems, Problem{node.Name(), "defined more than once"})
+			continue
+		}
+		position[node.Name()] = i
+	}
+
+	for i, node := range bn.nodesInOrder {
+		report := func(format string, args ...interface{}) {
+			problems = append(problems, Problem{node.Name(), fmt.Sprintf(format, args...)})
+		}
+		if len(node.def.PossibleValues) == 0 {
+			report("no possible values")
+		}
+		parents := make([]*BayesianNode, len(node.def.ParentNames))
+		for j, name := range node.def.ParentNames {
+			pos, ok := position[name]
+			switch {
+			case !ok:
+				report("unknown parent %s", name)
+			case pos >= i:
+				report("parent %s does not precede it", name)
+			}
+			parents[j] = bn.nodesByName[name]
+		}
+		for _, v := range node.values[len(node.def.PossibleValues):] {
+			report("probability for undeclared value %q", v)
+		}
+		for _, defect := range node.defects {
+			report("%s", defect)
+		}
+		if leaves := node.validateLevel(node.cpt, parents, nil, report); leaves == 0 {
+			report("no probabilities at the depth of its %d parents", len(parents))
+		}
+	}
+	return problems
+}
+
+// validateLevel checks one level of a table, reached by the parent values in
+// path, and the levels below it. It returns the number of leaves found.
+// Trained networks contain empty branches, which only fail lookups for parent
+// combinations that were never observed, so these are not reported.
+func (n *BayesianNode) validateLevel(c *cptNode, parents []*BayesianNode, path []string, report func(string, ...interface{})) int {
+	at := pathSuffix(path)
+	depth := len(path)
+	if depth == len(parents) {
+		if total := c.leaf.total(); math.Abs(total-1) > normalizationTolerance {
+			report("probabilities%s sum to %g", at, total)
+		}
+		return 1
+	}
+	parent := n.def.ParentNames[depth]
+	leaves := 0
+	for _, v := range sortedValues(c.deeper) {
+		if p := parents[depth]; p != nil {
+			if _, ok := p.index[v]; !ok {
+				report("table%s branches on %q, which is not a value of %s", at, v, parent)
+			}
+		}
+		leaves += n.validateLevel(c.deeper[v], parents, append(path, parent+"="+v), report)
+	}
+	if c.skip != nil {
+		leaves += n.validateLevel(c.skip, parents, append(path, parent+"=*"), report)
+	}
+	return leaves
+}
+
+// pathSuffix describes where in a table the parent values in path lead.
+func pathSuffix(path []string) string {
+	if len(path) == 0 {
+		return ""
+	}
+	return " at " + strings.Join(path, ", ")
+}
+
+func sortedValues(m map[string]*cptNode) []string {
+	values := make([]string, 0, len(m))
+	for v := range m {
+		values = append(values, v)
+	}
+	sort.Strings(values)
+	return values
+}
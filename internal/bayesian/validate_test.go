@@ -0,0 +1,61 @@
+package bayesian
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateSound(t *testing.T) {
+	if problems := newTestNetwork(t, weatherJSON).Validate(); problems != nil {
+		t.Errorf("Validate = %v, want nil", problems)
+	}
+}
+
+func TestValidateMalformed(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"negative probability", `{"nodes": [
+			{"name": "Rain", "parentNames": [], "possibleValues": ["yes", "no"],
+			 "conditionalProbabilities": {"yes": 1, "no": -0.5}}]}`,
+			`node Rain: negative probability -0.5 for "no"`},
+		{"negative probability in a branch", `{"nodes": [
+			{"name": "Rain", "parentNames": [], "possibleValues": ["yes", "no"],
+			 "conditionalProbabilities": {"yes": 0.2, "no": 0.8}},
+			{"name": "Sprinkler", "parentNames": ["Rain"], "possibleValues": ["on", "off"],
+			 "conditionalProbabilities": {"deeper": {
+				"yes": {"on": -0.01, "off": 1},
+				"no": {"on": 0.4, "off": 0.6}}}}]}`,
+			`node Sprinkler: negative probability -0.01 for "on" at Rain=yes`},
+		{"branch shallower than the parents", `{"nodes": [
+			{"name": "Rain", "parentNames": [], "possibleValues": ["yes", "no"],
+			 "conditionalProbabilities": {"yes": 0.2, "no": 0.8}},
+			{"name": "Sprinkler", "parentNames": ["Rain"], "possibleValues": ["on", "off"],
+			 "conditionalProbabilities": {"on": 0.4, "off": 0.6}},
+			{"name": "Grass", "parentNames": ["Sprinkler", "Rain"], "possibleValues": ["wet", "dry"],
+			 "conditionalProbabilities": {"deeper": {
+				"on": {"wet": 0.9, "dry": 0.1},
+				"off": {"skip": {"wet": 0.1, "dry": 0.9}}}}}]}`,
+			"node Sprinkler: table holds probabilities instead of branching on Rain\n" +
+				"node Sprinkler: no probabilities at the depth of its 1 parents\n" +
+				"node Grass: table at Sprinkler=on holds probabilities instead of branching on Rain"},
+	} {
+		bn, err := ParseNetwork([]byte(tc.raw))
+		if err != nil {
+			t.Fatalf("%s: ParseNetwork: %v", tc.name, err)
+		}
+		var got []string
+		for _, p := range bn.Validate() {
+			got = append(got, p.String())
+		}
+		if strings.Join(got, "\n") != tc.want {
+			t.Errorf("%s: Validate =\n%s\nwant\n%s", tc.name, strings.Join(got, "\n"), tc.want)
+		}
+		if _, err := loadNetwork([]byte(tc.raw)); !errors.Is(err, ErrCorruptNetwork) {
+			t.Errorf("%s: loadNetwork error = %v, want ErrCorruptNetwork", tc.name, err)
+		}
+	}
+}
@@ -2,45 +2,97 @@ package data
 
 import (
 	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
 )
 
+const (
+	InputNetworkFile       = "input-network.json"
+	HeaderNetworkFile      = "header-network.json"
+	FingerprintNetworkFile = "fingerprint-network.json"
+	HeadersOrderFile       = "headers-order.json"
+	BrowserHelperFile      = "browser-helper-file.json"
+	CountryZonesFile       = "country-zones.json"
+)
+
+//go:embed input-network.json header-network.json headers-order.json browser-helper-file.json country-zones.json
 var files embed.FS
 
-var InputNetwork []byte
+var (
+	// ErrMissingFile is wrapped by errors for model files a source does not
+	// provide.
+	ErrMissingFile = errors.New("missing data file")
+	// ErrCorruptFile is wrapped by errors for model files that cannot be
+	// decoded or contradict themselves.
+	ErrCorruptFile = errors.New("corrupt data file")
+)
 
-var HeaderNetwork []byte
+// Source supplies the model files by name. Implementations may return an
+// error wrapping fs.ErrNotExist for files they do not provide.
+type Source interface {
+	ReadFile(name string) ([]byte, error)
+}
 
-var FingerprintNetwork []byte
+type embeddedSource struct{}
 
-var HeadersOrder []byte
+func (embeddedSource) ReadFile(name string) ([]byte, error) {
+	return files.ReadFile(name)
+}
 
-var BrowserHelperFile []byte
+var Embedded Source = embeddedSource{}
 
-func init() {
-	var err error
+type fsSource struct {
+	fsys fs.FS
+}
 
-	InputNetwork, err = files.ReadFile("input-network.json")
-	if err != nil {
-		panic("failed to load input-network.json: " + err.Error())
-	}
+// FromFS reads model files from fsys. Files fsys does not contain are
+// missing; Overlay fills them in from another source.
+func FromFS(fsys fs.FS) Source {
+	return fsSource{fsys: fsys}
+}
 
-	HeaderNetwork, err = files.ReadFile("header-network.json")
+func FromDir(dir string) (Source, error) {
+	info, err := os.Stat(dir)
 	if err != nil {
-		panic("failed to load header-network.json: " + err.Error())
+		return nil, err
 	}
-
-	FingerprintNetwork, err = files.ReadFile("fingerprint-network.json")
-	if err != nil {
-		panic("failed to load fingerprint-network.json: " + err.Error())
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
 	}
+	return FromFS(os.DirFS(dir)), nil
+}
 
-	HeadersOrder, err = files.ReadFile("headers-order.json")
-	if err != nil {
-		panic("failed to load headers-order.json: " + err.Error())
+func (s fsSource) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(s.fsys, name)
+}
+
+type overlay struct {
+	top, base Source
+}
+
+// Overlay reads each model file from top, or from base when top does not
+// provide it.
+func Overlay(top, base Source) Source {
+	return overlay{top: top, base: base}
+}
+
+func (o overlay) ReadFile(name string) ([]byte, error) {
+	raw, err := o.top.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return o.base.ReadFile(name)
 	}
+	return raw, err
+}
 
-	BrowserHelperFile, err = files.ReadFile("browser-helper-file.json")
+func Read(src Source, name string) ([]byte, error) {
+	raw, err := src.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading %s: %w: %w", name, ErrMissingFile, err)
+	}
 	if err != nil {
-		panic("failed to load browser-helper-file.json: " + err.Error())
+		return nil, fmt.Errorf("reading %s: %w", name, err)
 	}
+	return raw, nil
 }
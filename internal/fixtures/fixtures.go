@@ -0,0 +1,26 @@
+// Package fixtures provides model files for tests. Its fingerprint network is
+// written by hand with made-up probabilities to exercise the generator; it is
+// not a trained model and must not be shipped as one.
+package fixtures
+
+import (
+	"embed"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/data"
+)
+
+//go:embed fingerprint-network.json
+var files embed.FS
+
+type source struct{}
+
+func (source) ReadFile(name string) ([]byte, error) {
+	if name == data.FingerprintNetworkFile {
+		return files.ReadFile(name)
+	}
+	return data.Embedded.ReadFile(name)
+}
+
+// Source serves the test fingerprint network and the embedded copies of
+// every other model file.
+var Source data.Source = source{}
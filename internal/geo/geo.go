@@ -0,0 +1,157 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+	_ "time/tzdata" // zones must resolve without a system zoneinfo database
+
+	"github.com/yourneighborhoodchef/browserforge/internal/data"
+)
+
+// Zone is an IANA time zone with the share of the country's users living in
+// it and a representative location.
+type Zone struct {
+	Name      string  `json:"zone"`
+	Weight    float64 `json:"weight"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+
+	location *time.Location
+}
+
+// Location returns the loaded time zone.
+func (z Zone) Location() *time.Location {
+	return z.location
+}
+
+type Country struct {
+	Code    string   `json:"-"`
+	Locales []string `json:"locales"`
+	Zones   []Zone   `json:"zones"`
+}
+
+// SampleZone picks one of the country's zones in proportion to its weight.
+func (c *Country) SampleZone(rng *rand.Rand) Zone {
+	var total float64
+	for _, z := range c.Zones {
+		total += z.Weight
+	}
+	r := rng.Float64() * total
+	for _, z := range c.Zones {
+		r -= z.Weight
+		if r < 0 {
+			return z
+		}
+	}
+	return c.Zones[len(c.Zones)-1]
+}
+
+// Table is immutable once loaded and may be shared between goroutines.
+type Table struct {
+	countries map[string]*Country
+	// languages maps a base language to the country most of its
+	// speakers are in.
+	languages map[string]string
+}
+
+var embedded struct {
+	once  sync.Once
+	table *Table
+	err   error
+}
+
+// Load reads the country table from src. The table built from the embedded
+// data is loaded once and shared.
+func Load(src data.Source) (*Table, error) {
+	if src == data.Embedded {
+		embedded.once.Do(func() {
+			embedded.table, embedded.err = load(src)
+		})
+		return embedded.table, embedded.err
+	}
+	return load(src)
+}
+
+func load(src data.Source) (*Table, error) {
+	raw, err := data.Read(src, data.CountryZonesFile)
+	if err != nil {
+		return nil, err
+	}
+	var def struct {
+		Countries map[string]*Country `json:"countries"`
+		Languages map[string]string   `json:"languages"`
+	}
+	if err := json.Unmarshal(raw, &def); err != nil {
+		return nil, fmt.Errorf("unmarshalling country zones: %w: %w", data.ErrCorruptFile, err)
+	}
+	for code, c := range def.Countries {
+		if len(c.Zones) == 0 {
+			return nil, fmt.Errorf("%w: country %s has no time zones", data.ErrCorruptFile, code)
+		}
+		c.Code = code
+		for i := range c.Zones {
+			loc, err := time.LoadLocation(c.Zones[i].Name)
+			if err != nil {
+				return nil, fmt.Errorf("%w: country %s: %w", data.ErrCorruptFile, code, err)
+			}
+			c.Zones[i].location = loc
+		}
+	}
+	for lang, code := range def.Languages {
+		if def.Countries[code] == nil {
+			return nil, fmt.Errorf("%w: language %s maps to unknown country %s", data.ErrCorruptFile, lang, code)
+		}
+	}
+	return &Table{countries: def.Countries, languages: def.Languages}, nil
+}
+
+func (t *Table) Country(code string) (*Country, bool) {
+	c, ok := t.countries[strings.ToUpper(code)]
+	return c, ok
+}
+
+// CountryFor returns the country that best matches a navigator.languages
+// list: the region of the first tag that names a known country, otherwise
+// the usual country of the first known base language.
+func (t *Table) CountryFor(languages []string) (*Country, bool) {
+	for _, tag := range languages {
+		parts := strings.Split(tag, "-")
+		region := parts[len(parts)-1]
+		if len(parts) > 1 && len(region) == 2 {
+			if c, ok := t.Country(region); ok {
+				return c, true
+			}
+		}
+	}
+	for _, tag := range languages {
+		base, _, _ := strings.Cut(tag, "-")
+		if code, ok := t.languages[strings.ToLower(base)]; ok {
+			return t.countries[code], true
+		}
+	}
+	return nil, false
+}
+
+// TimezoneOffset returns what Date.prototype.getTimezoneOffset reports in loc
+// at t: the minutes to add to local time to get UTC.
+func TimezoneOffset(loc *time.Location, t time.Time) int {
+	_, offset := t.In(loc).Zone()
+	return -offset / 60
+}
+
+const earthRadiusKm = 6371.0
+
+// Scatter returns a point drawn uniformly from the disc of radiusKm around
+// lat, lon.
+func Scatter(rng *rand.Rand, lat, lon, radiusKm float64) (float64, float64) {
+	dist := radiusKm * math.Sqrt(rng.Float64()) / earthRadiusKm
+	bearing := 2 * math.Pi * rng.Float64()
+	dLat := dist * math.Cos(bearing)
+	dLon := dist * math.Sin(bearing) / math.Cos(lat*math.Pi/180)
+	return lat + dLat*180/math.Pi, lon + dLon*180/math.Pi
+}
@@ -0,0 +1,297 @@
+package headers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/bayesian"
+)
+
+const (
+	browserHTTPNode     = "*BROWSER_HTTP"
+	httpVersionNode     = "*HTTP_VERSION"
+	operatingSystemNode = "*OPERATING_SYSTEM"
+	deviceNode          = "*DEVICE"
+)
+
+var httpVersionValues = map[string]string{
+	"1": "_1.1_",
+	"2": "_2.0_",
+}
+
+// Browser selects a browser by name and, optionally, by an inclusive range of
+// major versions and the HTTP version it was recorded with ("1" or "2"). Zero
+// values leave the corresponding bound open.
+type Browser struct {
+	Name        string
+	MinVersion  int
+	MaxVersion  int
+	HTTPVersion string
+}
+
+func (b Browser) String() string {
+	s := b.Name
+	if b.MinVersion > 0 {
+		s += fmt.Sprintf(" >= %d", b.MinVersion)
+	}
+	if b.MaxVersion > 0 {
+		s += fmt.Sprintf(" <= %d", b.MaxVersion)
+	}
+	if b.HTTPVersion != "" {
+		s += " over HTTP/" + b.HTTPVersion
+	}
+	return s
+}
+
+// matches reports whether a browser helper entry such as
+// "chrome/131.0.6778.154|2" satisfies b.
+func (b Browser) matches(entry string) bool {
+	browser, httpVersion, _ := strings.Cut(entry, "|")
+	name, version, _ := strings.Cut(browser, "/")
+	if !strings.EqualFold(name, b.Name) {
+		return false
+	}
+	if b.HTTPVersion != "" && httpVersion != b.HTTPVersion {
+		return false
+	}
+	if b.MinVersion == 0 && b.MaxVersion == 0 {
+		return true
+	}
+	major, ok := majorVersion(version)
+	if !ok {
+		return false
+	}
+	if b.MinVersion > 0 && major < b.MinVersion {
+		return false
+	}
+	if b.MaxVersion > 0 && major > b.MaxVersion {
+		return false
+	}
+	return true
+}
+
+func majorVersion(version string) (int, bool) {
+	major, _, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(major)
+	return n, err == nil
+}
+
+// Constraints restricts the input network to sets of acceptable values. An
+// empty field leaves the corresponding node unconstrained. UserAgent, when
+// set, is the User-Agent sent; the browser, operating system and other
+// headers are sampled given it or, if no browser allowed by the other
+// constraints sends it, as if it were not set. Locales, when set, replace the
+// Accept-Language header; several locales rule out the browsers that send
+// only one (see sendsSingleLocale).
+type Constraints struct {
+	Browsers         []Browser
+	OperatingSystems []string
+	Devices          []string
+	HTTPVersion      string
+	UserAgent        string
+	Locales          []string
+}
+
+// empty reports whether c leaves the input network unconstrained.
+func (c Constraints) empty() bool {
+	return len(c.Browsers) == 0 && len(c.OperatingSystems) == 0 && len(c.Devices) == 0 && c.HTTPVersion == "" && c.UserAgent == "" && len(c.Locales) <= 1
+}
+
+// key identifies the constraints that affect sampling. Of the locales only
+// whether there are several matters.
+func (c Constraints) key() string {
+	if len(c.Locales) > 1 {
+		c.Locales = []string{"*"}
+	} else {
+		c.Locales = nil
+	}
+	raw, _ := json.Marshal(c)
+	return string(raw)
+}
+
+// browserSpecs returns the browser specs with the HTTP version constraint
+// folded in.
+func (c Constraints) browserSpecs(hg *HeaderGenerator) []Browser {
+	specs := c.Browsers
+	if len(specs) == 0 && c.HTTPVersion != "" {
+		seen := make(map[string]bool)
+		for _, entry := range hg.uniqueBrowsers {
+			if name := browserName(entry); !seen[name] {
+				seen[name] = true
+				specs = append(specs, Browser{Name: name})
+			}
+		}
+	}
+	if c.HTTPVersion == "" {
+		return specs
+	}
+	pinned := make([]Browser, 0, len(specs))
+	for _, b := range specs {
+		if b.HTTPVersion == "" || b.HTTPVersion == c.HTTPVersion {
+			b.HTTPVersion = c.HTTPVersion
+			pinned = append(pinned, b)
+		}
+	}
+	return pinned
+}
+
+var (
+	// ErrUnknownBrowser is wrapped by errors for browser names the networks
+	// do not know.
+	ErrUnknownBrowser = errors.New("unknown browser")
+	// ErrInvalidConstraint is wrapped by errors for other constraint values
+	// the networks do not know or that are malformed.
+	ErrInvalidConstraint = errors.New("invalid constraint")
+)
+
+// Validate reports values that the loaded networks do not know about.
+func (hg *HeaderGenerator) Validate(c Constraints) error {
+	if _, ok := httpVersionValues[c.HTTPVersion]; c.HTTPVersion != "" && !ok {
+		return fmt.Errorf("%w: invalid HTTP version %q", ErrInvalidConstraint, c.HTTPVersion)
+	}
+	if len(c.Browsers) > 0 && len(c.browserSpecs(hg)) == 0 {
+		return fmt.Errorf("%w: no browser satisfies HTTP/%s", bayesian.ErrConstraintUnsatisfiable, c.HTTPVersion)
+	}
+	known := make(map[string]bool)
+	for _, entry := range hg.uniqueBrowsers {
+		known[browserName(entry)] = true
+	}
+	for _, b := range c.Browsers {
+		if !known[strings.ToLower(b.Name)] {
+			return fmt.Errorf("%w %q", ErrUnknownBrowser, b.Name)
+		}
+		if b.HTTPVersion != "" && b.HTTPVersion != "1" && b.HTTPVersion != "2" {
+			return fmt.Errorf("%w: invalid HTTP version %q for browser %s", ErrInvalidConstraint, b.HTTPVersion, b.Name)
+		}
+		if b.MaxVersion > 0 && b.MinVersion > b.MaxVersion {
+			return fmt.Errorf("%w: minimum version of browser %s exceeds maximum", ErrInvalidConstraint, b.Name)
+		}
+		if len(hg.matchingBrowsers([]Browser{b})) == 0 {
+			return fmt.Errorf("%w: no browser satisfies %s", bayesian.ErrConstraintUnsatisfiable, b)
+		}
+	}
+	if c.HTTPVersion != "" && len(hg.matchingBrowsers(c.browserSpecs(hg))) == 0 {
+		return fmt.Errorf("%w: no browser satisfies HTTP/%s", bayesian.ErrConstraintUnsatisfiable, c.HTTPVersion)
+	}
+	if err := ValidateLocales(c.Locales); err != nil {
+		return err
+	}
+	if c.UserAgent != "" {
+		w, err := hg.userAgentInputs(c)
+		if err != nil {
+			return err
+		}
+		if len(w.rows) == 0 {
+			// Sampled without the user agent; see GenerateWithConstraints.
+			c.UserAgent = ""
+		}
+	}
+	if len(c.Locales) > 1 && c.UserAgent == "" {
+		p, err := hg.inputNetwork.Probability(hg.inputRestrictions(c))
+		if errors.Is(err, bayesian.ErrConstraintUnsatisfiable) || err == nil && p == 0 {
+			return fmt.Errorf("%w: locales %v: the allowed browsers send a single locale", bayesian.ErrConstraintUnsatisfiable, c.Locales)
+		}
+	}
+	if err := hg.validateNodeValues(operatingSystemNode, "operating system", c.OperatingSystems); err != nil {
+		return err
+	}
+	if err := hg.validateNodeValues(deviceNode, "device category", c.Devices); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (hg *HeaderGenerator) validateNodeValues(nodeName, label string, values []string) error {
+	node := hg.inputNetwork.Node(nodeName)
+	if node == nil || len(values) == 0 {
+		return nil
+	}
+	known := make(map[string]bool)
+	for _, v := range node.PossibleValues() {
+		known[v] = true
+	}
+	for _, v := range values {
+		if !known[v] {
+			return fmt.Errorf("%w: unknown %s %q", ErrInvalidConstraint, label, v)
+		}
+	}
+	return nil
+}
+
+// matchingBrowsers expands browser specs into the browser/version|http
+// entries of the browser helper file, which are the values of the input
+// network's *BROWSER_HTTP node.
+func (hg *HeaderGenerator) matchingBrowsers(specs []Browser) []string {
+	var values []string
+	for _, entry := range hg.uniqueBrowsers {
+		for _, b := range specs {
+			if b.matches(entry) {
+				values = append(values, entry)
+				break
+			}
+		}
+	}
+	return values
+}
+
+// inputRestrictions expands the constraints into allowed values for the
+// input network nodes.
+func (hg *HeaderGenerator) inputRestrictions(c Constraints) map[string][]string {
+	allowed := make(map[string][]string)
+	if specs := c.browserSpecs(hg); len(specs) > 0 {
+		allowed[browserHTTPNode] = hg.matchingBrowsers(specs)
+	}
+	if c.HTTPVersion != "" {
+		allowed[httpVersionNode] = []string{httpVersionValues[c.HTTPVersion]}
+	}
+	if len(c.OperatingSystems) > 0 {
+		allowed[operatingSystemNode] = c.OperatingSystems
+	}
+	if len(c.Devices) > 0 {
+		allowed[deviceNode] = c.Devices
+	}
+	if len(c.Locales) > 1 {
+		hg.excludeSingleLocale(allowed)
+	}
+	return allowed
+}
+
+// posterior returns the input network conditioned on c. It is built once per
+// constraint set, as conditioning enumerates the input network.
+func (hg *HeaderGenerator) posterior(c Constraints) (*bayesian.Posterior, error) {
+	key := c.key()
+	if p, ok := hg.posteriors.Load(key); ok {
+		return p.(*bayesian.Posterior), nil
+	}
+	p, err := hg.inputNetwork.Condition(hg.inputRestrictions(c))
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := hg.posteriors.LoadOrStore(key, p)
+	return actual.(*bayesian.Posterior), nil
+}
+
+// excludeSingleLocale narrows allowed to the browsers that send more than one
+// locale.
+func (hg *HeaderGenerator) excludeSingleLocale(allowed map[string][]string) {
+	for _, node := range []string{browserHTTPNode, operatingSystemNode} {
+		values, ok := allowed[node]
+		if !ok {
+			values = hg.inputNetwork.Node(node).PossibleValues()
+		}
+		kept := make([]string, 0, len(values))
+		for _, v := range values {
+			browser, os := "", v
+			if node == browserHTTPNode {
+				browser, os = browserName(v), ""
+			}
+			if !sendsSingleLocale(browser, os) {
+				kept = append(kept, v)
+			}
+		}
+		allowed[node] = kept
+	}
+}
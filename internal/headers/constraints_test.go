@@ -0,0 +1,155 @@
+package headers
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/bayesian"
+)
+
+// chiSquareCritical approximates the 0.999 quantile of the chi-square
+// distribution with df degrees of freedom (Wilson-Hilferty).
+func chiSquareCritical(df int) float64 {
+	const z = 3.090
+	k := float64(df)
+	return k * math.Pow(1-2/(9*k)+z*math.Sqrt(2/(9*k)), 3)
+}
+
+// TestConditionalSampling checks the operating systems and browsers drawn
+// under constraints against the exact marginals of the conditioned input
+// network.
+func TestConditionalSampling(t *testing.T) {
+	hg := newTestGenerator(t)
+	c := Constraints{Devices: []string{"desktop"}, HTTPVersion: "2"}
+	p, err := hg.posterior(c)
+	if err != nil {
+		t.Fatalf("posterior: %v", err)
+	}
+	if again, _ := hg.posterior(c); again != p {
+		t.Error("posterior rebuilt for the same constraints")
+	}
+
+	const n = 20000
+	rng := rand.New(rand.NewSource(1))
+	counts := map[string]map[string]int{operatingSystemNode: {}, browserHTTPNode: {}}
+	for i := 0; i < n; i++ {
+		sample, err := p.Sample(rng)
+		if err != nil {
+			t.Fatalf("Sample: %v", err)
+		}
+		if sample[deviceNode] != "desktop" || sample[httpVersionNode] != httpVersionValues["2"] {
+			t.Fatalf("sample breaks the constraints: %v", sample)
+		}
+		for node := range counts {
+			counts[node][sample[node]]++
+		}
+	}
+
+	for node, observed := range counts {
+		marginal, err := hg.inputNetwork.Marginal(node, hg.inputRestrictions(c))
+		if err != nil {
+			t.Fatalf("Marginal(%s): %v", node, err)
+		}
+		// Values expected fewer than 5 times are pooled into one bin.
+		var stat, pooledExpected float64
+		pooledObserved, bins := 0, 0
+		for value, prob := range marginal {
+			expected := prob * n
+			if expected < 5 {
+				pooledExpected += expected
+				pooledObserved += observed[value]
+				continue
+			}
+			d := float64(observed[value]) - expected
+			stat += d * d / expected
+			bins++
+		}
+		if pooledExpected > 0 {
+			d := float64(pooledObserved) - pooledExpected
+			stat += d * d / pooledExpected
+			bins++
+		}
+		for value, count := range observed {
+			if _, ok := marginal[value]; !ok {
+				t.Errorf("%s: sampled %q %d times, which has probability 0", node, value, count)
+			}
+		}
+		if crit := chiSquareCritical(bins - 1); stat > crit {
+			t.Errorf("%s: chi-square %.1f over %d bins exceeds %.1f", node, stat, bins, crit)
+		}
+	}
+}
+
+func BenchmarkGenerateWithConstraints(b *testing.B) {
+	hg := newTestGenerator(b)
+	c := Constraints{Browsers: []Browser{{Name: "chrome"}}, Devices: []string{"desktop"}}
+	rng := rand.New(rand.NewSource(1))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := hg.GenerateWithConstraints(rng, c, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestBrowserMatches(t *testing.T) {
+	for _, tt := range []struct {
+		spec  Browser
+		entry string
+		want  bool
+	}{
+		{Browser{Name: "chrome"}, "chrome/131.0.6778.154|2", true},
+		{Browser{Name: "Chrome"}, "chrome/131.0.6778.154|2", true},
+		{Browser{Name: "chrome"}, "firefox/133.0|2", false},
+		{Browser{Name: "chrome", MinVersion: 131}, "chrome/131.0.6778.154|2", true},
+		{Browser{Name: "chrome", MinVersion: 132}, "chrome/131.0.6778.154|2", false},
+		{Browser{Name: "chrome", MaxVersion: 131}, "chrome/131.0.6778.154|2", true},
+		{Browser{Name: "chrome", MaxVersion: 130}, "chrome/131.0.6778.154|2", false},
+		{Browser{Name: "chrome", MinVersion: 120, MaxVersion: 120}, "chrome/120.0.0.0|1", true},
+		{Browser{Name: "chrome", MinVersion: 120, MaxVersion: 120}, "chrome/121.0.0.0|1", false},
+		{Browser{Name: "chrome", MinVersion: 120, MaxVersion: 120}, "chrome/119.0.0.0|2", false},
+		{Browser{Name: "chrome", HTTPVersion: "1"}, "chrome/131.0.0.0|1", true},
+		{Browser{Name: "chrome", HTTPVersion: "1"}, "chrome/131.0.0.0|2", false},
+		{Browser{Name: "chrome"}, "chrome/beta|2", true},
+		{Browser{Name: "chrome", MinVersion: 1}, "chrome/beta|2", false},
+	} {
+		if got := tt.spec.matches(tt.entry); got != tt.want {
+			t.Errorf("%s matches %q = %v, want %v", tt.spec, tt.entry, got, tt.want)
+		}
+	}
+}
+
+func TestValidateVersionRange(t *testing.T) {
+	hg := newTestGenerator(t)
+	for _, tt := range []struct {
+		spec Browser
+		want error
+	}{
+		{Browser{Name: "chrome", MinVersion: 131, MaxVersion: 131}, nil},
+		{Browser{Name: "chrome", MinVersion: 999}, bayesian.ErrConstraintUnsatisfiable},
+		{Browser{Name: "chrome", MaxVersion: 1}, bayesian.ErrConstraintUnsatisfiable},
+		{Browser{Name: "chrome", MinVersion: 131, MaxVersion: 120}, ErrInvalidConstraint},
+	} {
+		err := hg.Validate(Constraints{Browsers: []Browser{tt.spec}})
+		if tt.want == nil && err != nil || tt.want != nil && !errors.Is(err, tt.want) {
+			t.Errorf("Validate(%s) = %v, want %v", tt.spec, err, tt.want)
+		}
+	}
+	if err := hg.Validate(Constraints{Browsers: []Browser{{Name: "chrome", MinVersion: 999}}}); err == nil ||
+		!strings.Contains(err.Error(), "no browser satisfies chrome >= 999") {
+		t.Errorf("Validate(chrome >= 999) = %v, want it to name the browser", err)
+	}
+
+	matched := hg.matchingBrowsers([]Browser{{Name: "chrome", MinVersion: 131, MaxVersion: 131}})
+	if len(matched) == 0 {
+		t.Fatal("no chrome 131 in the browser helper file")
+	}
+	for _, entry := range matched {
+		if !strings.HasPrefix(entry, "chrome/131.") {
+			t.Errorf("chrome 131 to 131 matched %q", entry)
+		}
+	}
+}
@@ -3,12 +3,28 @@ package headers
 import (
 	"encoding/json"
 	"fmt"
+	"math/rand"
+	"sort"
 	"strings"
+	"sync"
 
 	"github.com/yourneighborhoodchef/browserforge/internal/bayesian"
 	"github.com/yourneighborhoodchef/browserforge/internal/data"
 )
 
+// missingToken is the value of nodes whose header is not sent.
+const missingToken = "*MISSING_VALUE*"
+
+// pseudoHeaders are the HTTP/2 request pseudo-header fields, with the values
+// of a top-level navigation. The authority is left empty until a request
+// supplies it.
+var pseudoHeaders = []Header{
+	{Name: ":method", Value: "GET"},
+	{Name: ":authority", Value: ""},
+	{Name: ":scheme", Value: "https"},
+	{Name: ":path", Value: "/"},
+}
+
 type HeaderGenerator struct {
 	inputNetwork  *bayesian.BayesianNetwork
 	headerNetwork *bayesian.BayesianNetwork
@@ -16,9 +32,24 @@ type HeaderGenerator struct {
 	headersOrder map[string][]string
 
 	uniqueBrowsers []string
+
+	// inputs is the joint distribution of the input network's nodes,
+	// computed on first use.
+	inputs struct {
+		once sync.Once
+		p    *bayesian.Posterior
+		err  error
+	}
+	// posteriors caches the *bayesian.Posterior of each constraint set.
+	posteriors sync.Map
+	// userAgents caches the *weightedInputs of each constraint set with a
+	// user agent.
+	userAgents sync.Map
 }
 
-func pascalize(name string) string {
+// Pascalize returns the HTTP/1.1 spelling browsers use for a header name,
+// e.g. "Accept-Encoding" or "DNT". Client hints keep their lowercase names.
+func Pascalize(name string) string {
 
 	if strings.HasPrefix(name, ":") || strings.HasPrefix(name, "sec-ch-ua") {
 		return name
@@ -46,22 +77,49 @@ func title(s string) string {
 	return strings.ToUpper(s[:1]) + strings.ToLower(s[1:])
 }
 
-func NewHeaderGenerator() (*HeaderGenerator, error) {
-	inNet, err := bayesian.LoadInputNetwork()
+var embedded struct {
+	once sync.Once
+	hg   *HeaderGenerator
+	err  error
+}
+
+// NewHeaderGenerator returns a generator backed by src. The generator is
+// read-only, so the one built from the embedded data is created once and
+// shared.
+func NewHeaderGenerator(src data.Source) (*HeaderGenerator, error) {
+	if src == data.Embedded {
+		embedded.once.Do(func() {
+			embedded.hg, embedded.err = loadHeaderGenerator(src)
+		})
+		return embedded.hg, embedded.err
+	}
+	return loadHeaderGenerator(src)
+}
+
+func loadHeaderGenerator(src data.Source) (*HeaderGenerator, error) {
+	inNet, err := bayesian.LoadInputNetwork(src)
 	if err != nil {
 		return nil, fmt.Errorf("loading input network: %w", err)
 	}
-	hNet, err := bayesian.LoadHeaderNetwork()
+	hNet, err := bayesian.LoadHeaderNetwork(src)
 	if err != nil {
 		return nil, fmt.Errorf("loading header network: %w", err)
 	}
+	rawOrder, err := data.Read(src, data.HeadersOrderFile)
+	if err != nil {
+		return nil, err
+	}
 	var order map[string][]string
-	if err := json.Unmarshal(data.HeadersOrder, &order); err != nil {
-		return nil, fmt.Errorf("unmarshalling headers order: %w", err)
+	if err := json.Unmarshal(rawOrder, &order); err != nil {
+		return nil, fmt.Errorf("unmarshalling headers order: %w: %w", data.ErrCorruptFile, err)
+	}
+	rawBrowsers, err := data.Read(src, data.BrowserHelperFile)
+	if err != nil {
+		return nil, err
 	}
 	var unique []string
-	if err := json.Unmarshal(data.BrowserHelperFile, &unique); err != nil {
-		return nil, fmt.Errorf("unmarshalling browser helper file: %w", err)
+	if err := json.Unmarshal(rawBrowsers, &unique); err != nil {
+		return nil, fmt.Errorf("unmarshalling browser helper file: %w: %w", data.ErrCorruptFile, err)
 	}
 	return &HeaderGenerator{
 		inputNetwork:   inNet,
@@ -71,41 +129,56 @@ func NewHeaderGenerator() (*HeaderGenerator, error) {
 	}, nil
 }
 
-func (hg *HeaderGenerator) Generate() (map[string]string, error) {
+func (hg *HeaderGenerator) Generate(rng *rand.Rand) (OrderedHeaders, error) {
 
-	return hg.GenerateWithConstraints(nil, nil)
+	return hg.GenerateWithConstraints(rng, Constraints{}, nil)
 }
 
 func (hg *HeaderGenerator) GenerateWithConstraints(
-	inputNetConstraints map[string]string,
+	rng *rand.Rand,
+	constraints Constraints,
 	requestDependent map[string]string,
-) (map[string]string, error) {
+) (OrderedHeaders, error) {
 
-	inSample := make(map[string]string)
-	if inputNetConstraints != nil {
-		for k, v := range inputNetConstraints {
-			inSample[k] = v
+	var inputSample map[string]string
+	var err error
+	if constraints.UserAgent != "" {
+		inputSample, err = hg.sampleUserAgentInputs(rng, constraints)
+		if inputSample == nil && err == nil {
+			// No browser sends the user agent: the inputs are sampled
+			// without it and only the header is replaced.
+			constraints.UserAgent = ""
+		}
+	}
+	switch {
+	case inputSample != nil || err != nil:
+	case constraints.empty():
+		inputSample, err = hg.inputNetwork.GenerateSample(rng, nil)
+	default:
+		var p *bayesian.Posterior
+		if p, err = hg.posterior(constraints); err == nil {
+			inputSample, err = p.Sample(rng)
 		}
 	}
-	inputSample, err := hg.inputNetwork.GenerateSample(inSample)
 	if err != nil {
 		return nil, fmt.Errorf("sampling input network: %w", err)
 	}
 
-	if requestDependent != nil {
-		for k, v := range requestDependent {
-
-			inputSample[k] = v
-		}
-	}
-
-	sample, err := hg.headerNetwork.GenerateSample(inputSample)
+	sample, err := hg.headerNetwork.GenerateSample(rng, inputSample)
 	if err != nil {
 		return nil, fmt.Errorf("sampling header network: %w", err)
 	}
 
-	const missingToken = "*MISSING_VALUE*"
-	filtered := make(map[string]string, len(sample))
+	// Field names are spelled for the sampled HTTP version, which the
+	// requested one constrains.
+	http2 := sample[httpVersionNode] == httpVersionValues["2"]
+
+	filtered := make(map[string]string, len(sample)+len(pseudoHeaders))
+	if http2 {
+		for _, hdr := range pseudoHeaders {
+			filtered[hdr.Name] = hdr.Value
+		}
+	}
 	for key, val := range sample {
 		if strings.HasPrefix(key, "*") {
 			continue
@@ -118,9 +191,112 @@ func (hg *HeaderGenerator) GenerateWithConstraints(
 		}
 		filtered[key] = val
 	}
+
+	browser := browserName(sample["*BROWSER"])
+	overrides := make(map[string]string, len(requestDependent)+1)
+	if len(constraints.Locales) > 0 {
+		overrides["Accept-Language"] = AcceptLanguage(browser, constraints.Locales)
+	}
+	for name, val := range requestDependent {
+		overrides[name] = val
+	}
+
+	// Overrides replace the sampled value, keeping the sampled field name
+	// so its casing and position are preserved.
+	for name, val := range overrides {
+		replaced := false
+		for key := range filtered {
+			if strings.EqualFold(key, name) {
+				filtered[key] = val
+				replaced = true
+			}
+		}
+		if !replaced {
+			filtered[name] = val
+		}
+	}
+
 	result := make(map[string]string, len(filtered))
 	for key, val := range filtered {
-		result[pascalize(key)] = val
+		switch {
+		case http2:
+			// HTTP/2 sends lowercase field names.
+			result[strings.ToLower(key)] = val
+		case !strings.HasPrefix(key, ":"):
+			result[Pascalize(key)] = val
+		}
+	}
+	return hg.orderHeaders(result, browser), nil
+}
+
+func browserName(browser string) string {
+	name, _, _ := strings.Cut(browser, "/")
+	return name
+}
+
+func (hg *HeaderGenerator) orderFor(browser string) []string {
+	if order := hg.headersOrder[browser]; len(order) > 0 {
+		return order
 	}
-	return result, nil
+
+	return hg.headersOrder["chrome"]
+}
+
+// Order returns hdrs sorted into the order browser sends them in, falling
+// back to Chrome's order for browsers without an entry of their own.
+func (hg *HeaderGenerator) Order(hdrs OrderedHeaders, browser string) OrderedHeaders {
+	return hg.orderHeaders(hdrs.Map(), browser)
+}
+
+// orderSection returns the part of a browser's order for one HTTP version.
+// The HTTP/2 names follow the HTTP/1.1 ones, starting at the first
+// pseudo-header.
+func orderSection(order []string, version string) []string {
+	for i, name := range order {
+		if !strings.HasPrefix(name, ":") {
+			continue
+		}
+		switch version {
+		case "1":
+			return order[:i]
+		case "2":
+			return order[i:]
+		}
+		break
+	}
+	return order
+}
+
+func (hg *HeaderGenerator) orderHeaders(hdrs map[string]string, browser string) OrderedHeaders {
+	result := make(OrderedHeaders, 0, len(hdrs))
+	for name, value := range hdrs {
+		result = append(result, Header{Name: name, Value: value})
+	}
+	order := orderSection(hg.orderFor(browser), httpVersionOf(result))
+	rank := func(name string) int {
+		for i, known := range order {
+			if known == name {
+				return i
+			}
+		}
+		for i, known := range order {
+			if strings.EqualFold(known, name) {
+				return i
+			}
+		}
+		return len(order)
+	}
+
+	ranks := make(map[string]int, len(hdrs))
+	for name := range hdrs {
+		ranks[name] = rank(name)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		ri, rj := ranks[result[i].Name], ranks[result[j].Name]
+		if ri != rj {
+			return ri < rj
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
 }
@@ -0,0 +1,90 @@
+package headers
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/data"
+)
+
+func newTestGenerator(t testing.TB) *HeaderGenerator {
+	t.Helper()
+	hg, err := NewHeaderGenerator(data.Embedded)
+	if err != nil {
+		t.Fatalf("NewHeaderGenerator: %v", err)
+	}
+	return hg
+}
+
+func TestGenerateSpellsSampledHTTPVersion(t *testing.T) {
+	hg := newTestGenerator(t)
+	rng := rand.New(rand.NewSource(1))
+	seen := map[string]int{}
+	for i := 0; i < 300; i++ {
+		hdrs, err := hg.Generate(rng)
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		version := httpVersionOf(hdrs)
+		seen[version]++
+		_, hasPseudo := hdrs.Lookup(":method")
+		if hasPseudo != (version == "2") {
+			t.Fatalf("HTTP/%s headers with pseudo-headers %v: %v", version, hasPseudo, hdrs.Names())
+		}
+		for _, hdr := range hdrs {
+			if version == "2" && hdr.Name != strings.ToLower(hdr.Name) {
+				t.Fatalf("HTTP/2 header %q is not lowercase", hdr.Name)
+			}
+			if version == "1" && hdr.Name != Pascalize(hdr.Name) {
+				t.Fatalf("HTTP/1.1 header %q is not Pascal-cased", hdr.Name)
+			}
+		}
+	}
+	if seen["1"] == 0 || seen["2"] == 0 {
+		t.Errorf("HTTP versions seen: %v, want both", seen)
+	}
+}
+
+func TestPseudoHeaderOrder(t *testing.T) {
+	hg := newTestGenerator(t)
+	c := Constraints{HTTPVersion: "2"}
+	for _, browser := range []string{"chrome", "firefox", "safari"} {
+		c.Browsers = []Browser{{Name: browser}}
+		hdrs, err := hg.GenerateWithConstraints(rand.New(rand.NewSource(2)), c, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", browser, err)
+		}
+		var want []string
+		for _, name := range hg.orderFor(browser) {
+			if strings.HasPrefix(name, ":") {
+				want = append(want, name)
+			}
+		}
+		got := hdrs.Names()
+		if len(got) < len(want) {
+			t.Fatalf("%s: headers %v lack pseudo-headers", browser, got)
+		}
+		if strings.Join(got[:len(want)], " ") != strings.Join(want, " ") {
+			t.Errorf("%s: headers start with %v, want %v", browser, got[:len(want)], want)
+		}
+	}
+}
+
+func TestLikelihoodOfGeneratedHeaders(t *testing.T) {
+	hg := newTestGenerator(t)
+	rng := rand.New(rand.NewSource(3))
+	for i := 0; i < 50; i++ {
+		hdrs, err := hg.Generate(rng)
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		p, err := hg.Likelihood(hdrs, false)
+		if err != nil {
+			t.Fatalf("Likelihood: %v", err)
+		}
+		if p <= 0 || p > 1 {
+			t.Errorf("Likelihood(%v) = %g", hdrs, p)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package headers
+
+import (
+	"strings"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/bayesian"
+)
+
+// Likelihood returns the probability that the generator produces hdrs,
+// summed over the browser, operating system, device and HTTP version they
+// could have been sampled for. Headers that are not nodes of the header
+// network, such as Accept-Language, are ignored. Nodes without a header are
+// observed as missing unless partial is set, for header sets that were
+// filtered after sampling.
+func (hg *HeaderGenerator) Likelihood(hdrs OrderedHeaders, partial bool) (float64, error) {
+	inputs, err := hg.inputJoint()
+	if err != nil {
+		return 0, err
+	}
+
+	// Field names are spelled for the sampled HTTP version. Headers that do
+	// not show it, such as client hints alone, are matched to the nodes of
+	// each version in turn.
+	version := httpVersionOf(hdrs)
+	http1 := hg.observe(hdrs, partial, false)
+	http2 := hg.observe(hdrs, partial, true)
+	total := 0.0
+	inputs.Each(func(values map[string]string, p float64) {
+		observed := http1
+		if values[httpVersionNode] == httpVersionValues["2"] {
+			observed = http2
+		}
+		if version != "" && values[httpVersionNode] != httpVersionValues[version] {
+			return
+		}
+		total += p * hg.headerNetwork.Likelihood(values, observed)
+	})
+	return total, nil
+}
+
+// httpVersionOf returns "2" for headers with pseudo-header fields or
+// lowercase names, "1" for Pascal-cased names, and "" when the names do not
+// tell.
+func httpVersionOf(hdrs OrderedHeaders) string {
+	for _, hdr := range hdrs {
+		switch pascal := Pascalize(hdr.Name); {
+		case strings.HasPrefix(hdr.Name, ":"):
+			return "2"
+		case pascal == hdr.Name && strings.ToLower(hdr.Name) != hdr.Name:
+			return "1"
+		case pascal != hdr.Name && strings.ToLower(hdr.Name) == hdr.Name:
+			return "2"
+		}
+	}
+	return ""
+}
+
+// inputJoint returns the joint distribution of the header network's inputs
+// and their ancestors in the input network.
+func (hg *HeaderGenerator) inputJoint() (*bayesian.Posterior, error) {
+	hg.inputs.once.Do(func() {
+		var inputs []string
+		for _, node := range hg.headerNetwork.Nodes() {
+			if hg.inputNetwork.Node(node.Name()) != nil {
+				inputs = append(inputs, node.Name())
+			}
+		}
+		hg.inputs.p, hg.inputs.err = hg.inputNetwork.Joint(inputs...)
+	})
+	return hg.inputs.p, hg.inputs.err
+}
+
+// observe maps hdrs to values of the header network's nodes, preferring the
+// lowercase nodes for HTTP/2.
+func (hg *HeaderGenerator) observe(hdrs OrderedHeaders, partial, http2 bool) map[string][]string {
+	observed := make(map[string][]string)
+	for _, hdr := range hdrs {
+		if node := hg.headerNode(hdr.Name, http2); node != "" && observed[node] == nil {
+			observed[node] = []string{hdr.Value}
+		}
+	}
+	if partial {
+		return observed
+	}
+	for _, node := range hg.headerNetwork.Nodes() {
+		name := node.Name()
+		if strings.HasPrefix(name, "*") || observed[name] != nil {
+			continue
+		}
+		observed[name] = []string{missingToken}
+		if strings.EqualFold(name, "connection") {
+			// Connection: close is sampled but never sent.
+			observed[name] = append(observed[name], "close")
+		}
+	}
+	return observed
+}
+
+// headerNode returns the node for a header in any casing, preferring
+// lowercase nodes for HTTP/2 where both spellings exist.
+func (hg *HeaderGenerator) headerNode(header string, http2 bool) string {
+	found := ""
+	for _, node := range hg.headerNetwork.Nodes() {
+		name := node.Name()
+		if !strings.EqualFold(name, header) {
+			continue
+		}
+		if found == "" || (name == strings.ToLower(name)) == http2 {
+			found = name
+		}
+	}
+	return found
+}
@@ -0,0 +1,82 @@
+package headers
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestLikelihoodPartial(t *testing.T) {
+	hg := newTestGenerator(t)
+	if p, err := hg.Likelihood(nil, true); err != nil || math.Abs(p-1) > 1e-9 {
+		t.Errorf("Likelihood of no headers = %v, %v; want 1", p, err)
+	}
+
+	rng := rand.New(rand.NewSource(4))
+	for i := 0; i < 20; i++ {
+		hdrs, err := hg.Generate(rng)
+		if err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		full, err := hg.Likelihood(hdrs, false)
+		if err != nil {
+			t.Fatalf("Likelihood: %v", err)
+		}
+
+		// Accept-Encoding alone depends on the user agent, which is summed
+		// over.
+		value, ok := hdrs.Lookup("Accept-Encoding")
+		if !ok {
+			t.Fatalf("headers without Accept-Encoding: %v", hdrs.Names())
+		}
+		name := "Accept-Encoding"
+		if httpVersionOf(hdrs) == "2" {
+			name = "accept-encoding"
+		}
+		partial, err := hg.Likelihood(OrderedHeaders{{Name: name, Value: value}}, true)
+		if err != nil {
+			t.Fatalf("Likelihood: %v", err)
+		}
+		if partial <= 0 || partial > 1 || partial < full {
+			t.Errorf("Likelihood of %s: %q = %v, of all headers %v", name, value, partial, full)
+		}
+	}
+}
+
+func TestLikelihoodImpossible(t *testing.T) {
+	hg := newTestGenerator(t)
+	hdrs, err := hg.Generate(rand.New(rand.NewSource(5)))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	name := "Accept-Encoding"
+	if httpVersionOf(hdrs) == "2" {
+		name = "accept-encoding"
+	}
+	hdrs.Set(name, "no such coding")
+	for _, partial := range []bool{false, true} {
+		if p, err := hg.Likelihood(hdrs, partial); err != nil || p != 0 {
+			t.Errorf("Likelihood(partial=%v) with an impossible value = %v, %v; want 0", partial, p, err)
+		}
+	}
+}
+
+func TestLikelihoodIgnoresUnknownHeaders(t *testing.T) {
+	hg := newTestGenerator(t)
+	hdrs, err := hg.Generate(rand.New(rand.NewSource(6)))
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	want, err := hg.Likelihood(hdrs, false)
+	if err != nil {
+		t.Fatalf("Likelihood: %v", err)
+	}
+	name := "X-Requested-With"
+	if httpVersionOf(hdrs) == "2" {
+		name = "x-requested-with"
+	}
+	hdrs.Set(name, "XMLHttpRequest")
+	if got, err := hg.Likelihood(hdrs, false); err != nil || got != want {
+		t.Errorf("Likelihood with %s = %v, %v; want %v", name, got, err, want)
+	}
+}
@@ -0,0 +1,163 @@
+package headers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CanonicalLocale checks that tag is a BCP 47 language tag of the form
+// language[-Script][-REGION] and returns it in canonical case, e.g.
+// "en-us" becomes "en-US" and "zh-hant-tw" becomes "zh-Hant-TW".
+func CanonicalLocale(tag string) (string, error) {
+	parts := strings.Split(strings.ReplaceAll(tag, "_", "-"), "-")
+	if len(parts) > 3 || !isLanguageSubtag(parts[0]) {
+		return "", fmt.Errorf("invalid locale %q", tag)
+	}
+	out := []string{strings.ToLower(parts[0])}
+	rest := parts[1:]
+	if len(rest) > 0 && len(rest[0]) == 4 && isAlpha(rest[0]) {
+		out = append(out, title(rest[0]))
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		region := rest[0]
+		switch {
+		case len(region) == 2 && isAlpha(region):
+			out = append(out, strings.ToUpper(region))
+		case len(region) == 3 && isDigits(region):
+			out = append(out, region)
+		default:
+			return "", fmt.Errorf("invalid locale %q", tag)
+		}
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		return "", fmt.Errorf("invalid locale %q", tag)
+	}
+	return strings.Join(out, "-"), nil
+}
+
+// ValidateLocales reports malformed or repeated locales.
+func ValidateLocales(locales []string) error {
+	seen := make(map[string]bool, len(locales))
+	for _, tag := range locales {
+		canonical, err := CanonicalLocale(tag)
+		if err != nil {
+			return err
+		}
+		if seen[canonical] {
+			return fmt.Errorf("duplicate locale %q", tag)
+		}
+		seen[canonical] = true
+	}
+	return nil
+}
+
+// sendsSingleLocale reports whether a browser sends only the first of its
+// locales, in Accept-Language and navigator.languages alike. Safari does, and
+// so does every browser on iOS, as they all run on WebKit. Either argument
+// may be empty when it is not known.
+func sendsSingleLocale(browser, os string) bool {
+	return browser == "safari" || os == "ios"
+}
+
+// AcceptLanguage formats locales the way browser sends them. Firefox lists
+// the locales as configured and spreads the q-values evenly below 1.
+// Chromium-based browsers and Safari add the base language after each group
+// of regional variants and lower the q-value in steps of 0.1 down to 0.1.
+func AcceptLanguage(browser string, locales []string) string {
+	if len(locales) == 0 {
+		return ""
+	}
+	if browser == "firefox" {
+		return firefoxAcceptLanguage(locales)
+	}
+	return chromiumAcceptLanguage(locales)
+}
+
+func firefoxAcceptLanguage(locales []string) string {
+	var b strings.Builder
+	n := len(locales)
+	// Firefox switches to two decimals once one decimal can no longer
+	// tell the entries apart.
+	prec := 1
+	if n >= 10 {
+		prec = 2
+	}
+	for i, tag := range locales {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(tag)
+		if i > 0 {
+			q := 1 - float64(i)/float64(n)
+			b.WriteString(";q=")
+			b.WriteString(strconv.FormatFloat(q, 'f', prec, 64))
+		}
+	}
+	return b.String()
+}
+
+func chromiumAcceptLanguage(locales []string) string {
+	expanded := make([]string, 0, 2*len(locales))
+	seen := make(map[string]bool, 2*len(locales))
+	add := func(tag string) {
+		if !seen[tag] {
+			seen[tag] = true
+			expanded = append(expanded, tag)
+		}
+	}
+	for i, tag := range locales {
+		add(tag)
+		base := baseLanguage(tag)
+		if i+1 < len(locales) && baseLanguage(locales[i+1]) == base {
+			continue
+		}
+		add(base)
+	}
+
+	var b strings.Builder
+	q := 10
+	for i, tag := range expanded {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(tag)
+		if i > 0 {
+			if q > 1 {
+				q--
+			}
+			b.WriteString(";q=0.")
+			b.WriteString(strconv.Itoa(q))
+		}
+	}
+	return b.String()
+}
+
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(tag, "-")
+	return base
+}
+
+func isLanguageSubtag(s string) bool {
+	return len(s) >= 2 && len(s) <= 3 && isAlpha(s)
+}
+
+func isAlpha(s string) bool {
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') {
+			return false
+		}
+	}
+	return true
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package headers
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/yourneighborhoodchef/browserforge/internal/bayesian"
+)
+
+// webKitOnly reports whether ua is Safari or a browser on iOS.
+func webKitOnly(ua string) bool {
+	if strings.Contains(ua, "iPhone") || strings.Contains(ua, "iPad") {
+		return true
+	}
+	return strings.Contains(ua, "Version/") && strings.Contains(ua, "Safari/") && !strings.Contains(ua, "Chrome/")
+}
+
+func TestSeveralLocalesExcludeWebKit(t *testing.T) {
+	hg := newTestGenerator(t)
+	rng := rand.New(rand.NewSource(1))
+	for _, tc := range []struct {
+		locales []string
+		webKit  bool
+	}{
+		{[]string{"en-US"}, true},
+		{[]string{"en-US", "de-DE"}, false},
+	} {
+		webKit := false
+		for i := 0; i < 500; i++ {
+			hdrs, err := hg.GenerateWithConstraints(rng, Constraints{Locales: tc.locales}, nil)
+			if err != nil {
+				t.Fatalf("%v: GenerateWithConstraints: %v", tc.locales, err)
+			}
+			webKit = webKit || webKitOnly(hdrs.Get("User-Agent"))
+		}
+		if webKit != tc.webKit {
+			t.Errorf("%v: sampled Safari or iOS = %v, want %v", tc.locales, webKit, tc.webKit)
+		}
+	}
+}
+
+func TestSeveralLocalesConflict(t *testing.T) {
+	hg := newTestGenerator(t)
+	two := []string{"en-US", "de-DE"}
+	for _, tc := range []struct {
+		c    Constraints
+		fail bool
+	}{
+		{Constraints{Browsers: []Browser{{Name: "safari"}}, Locales: two[:1]}, false},
+		{Constraints{Browsers: []Browser{{Name: "safari"}}, Locales: two}, true},
+		{Constraints{OperatingSystems: []string{"ios"}, Locales: two}, true},
+		{Constraints{Browsers: []Browser{{Name: "safari"}, {Name: "firefox"}}, Locales: two}, false},
+	} {
+		err := hg.Validate(tc.c)
+		if got := errors.Is(err, bayesian.ErrConstraintUnsatisfiable); got != tc.fail {
+			t.Errorf("Validate(%+v) = %v, want unsatisfiable %v", tc.c, err, tc.fail)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package headers
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type Header struct {
+	Name  string
+	Value string
+}
+
+// OrderedHeaders keeps headers in the order a browser puts them on the wire.
+// It marshals to a JSON object whose keys follow that order.
+type OrderedHeaders []Header
+
+func (h OrderedHeaders) Lookup(name string) (string, bool) {
+	for _, hdr := range h {
+		if strings.EqualFold(hdr.Name, name) {
+			return hdr.Value, true
+		}
+	}
+	return "", false
+}
+
+func (h OrderedHeaders) Get(name string) string {
+	v, _ := h.Lookup(name)
+	return v
+}
+
+// Set replaces the value of the first header named name, compared
+// case-insensitively, keeping its spelling and position. Later headers with
+// that name are removed. A header that is not present is appended.
+func (h *OrderedHeaders) Set(name, value string) {
+	found := false
+	kept := (*h)[:0]
+	for _, hdr := range *h {
+		if strings.EqualFold(hdr.Name, name) {
+			if found {
+				continue
+			}
+			found = true
+			hdr.Value = value
+		}
+		kept = append(kept, hdr)
+	}
+	if !found {
+		kept = append(kept, Header{Name: name, Value: value})
+	}
+	*h = kept
+}
+
+// Del removes every header named name, compared case-insensitively.
+func (h *OrderedHeaders) Del(name string) {
+	kept := (*h)[:0]
+	for _, hdr := range *h {
+		if !strings.EqualFold(hdr.Name, name) {
+			kept = append(kept, hdr)
+		}
+	}
+	*h = kept
+}
+
+func (h OrderedHeaders) Clone() OrderedHeaders {
+	if h == nil {
+		return nil
+	}
+	return append(OrderedHeaders(nil), h...)
+}
+
+func (h OrderedHeaders) Names() []string {
+	names := make([]string, len(h))
+	for i, hdr := range h {
+		names[i] = hdr.Name
+	}
+	return names
+}
+
+func (h OrderedHeaders) Map() map[string]string {
+	m := make(map[string]string, len(h))
+	for _, hdr := range h {
+		m[hdr.Name] = hdr.Value
+	}
+	return m
+}
+
+func (h OrderedHeaders) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, hdr := range h {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(hdr.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(hdr.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func (h *OrderedHeaders) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok == nil {
+		*h = nil
+		return nil
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return fmt.Errorf("headers: expected JSON object, got %v", tok)
+	}
+	var result OrderedHeaders
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name, ok := tok.(string)
+		if !ok {
+			return fmt.Errorf("headers: expected string key, got %v", tok)
+		}
+		var value string
+		if err := dec.Decode(&value); err != nil {
+			return fmt.Errorf("headers: value of %s: %w", name, err)
+		}
+		result = append(result, Header{Name: name, Value: value})
+	}
+	if _, err := dec.Token(); err != nil {
+		return err
+	}
+	*h = result
+	return nil
+}
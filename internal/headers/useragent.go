@@ -0,0 +1,86 @@
+package headers
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// userAgentNodes are the header network's User-Agent nodes for each value of
+// *HTTP_VERSION.
+var userAgentNodes = map[string]string{
+	httpVersionValues["1"]: "User-Agent",
+	httpVersionValues["2"]: "user-agent",
+}
+
+// weightedInputs are the input network assignments that can send a user
+// agent, weighted by their probability times that of the user agent. They are
+// empty for a user agent no assignment sends.
+type weightedInputs struct {
+	rows []map[string]string
+	cum  []float64
+}
+
+// userAgentInputs returns the inputs for c.UserAgent under the other
+// constraints.
+func (hg *HeaderGenerator) userAgentInputs(c Constraints) (*weightedInputs, error) {
+	key := c.key()
+	if w, ok := hg.userAgents.Load(key); ok {
+		return w.(*weightedInputs), nil
+	}
+	joint, err := hg.inputJoint()
+	if err != nil {
+		return nil, err
+	}
+	restrictions := hg.inputRestrictions(c)
+	w := new(weightedInputs)
+	total := 0.0
+	joint.Each(func(values map[string]string, p float64) {
+		for node, allowed := range restrictions {
+			if !containsValue(allowed, values[node]) {
+				return
+			}
+		}
+		node := userAgentNodes[values[httpVersionNode]]
+		p *= hg.headerNetwork.Likelihood(values, map[string][]string{node: {c.UserAgent}})
+		if p == 0 {
+			return
+		}
+		row := make(map[string]string, len(values)+1)
+		for name, v := range values {
+			row[name] = v
+		}
+		row[node] = c.UserAgent
+		total += p
+		w.rows = append(w.rows, row)
+		w.cum = append(w.cum, total)
+	})
+	actual, _ := hg.userAgents.LoadOrStore(key, w)
+	return actual.(*weightedInputs), nil
+}
+
+// sampleUserAgentInputs draws the inputs, and the User-Agent node, for a
+// sample sending c.UserAgent. It returns nil if no input sends it.
+func (hg *HeaderGenerator) sampleUserAgentInputs(rng *rand.Rand, c Constraints) (map[string]string, error) {
+	w, err := hg.userAgentInputs(c)
+	if err != nil || len(w.rows) == 0 {
+		return nil, err
+	}
+	i := sort.SearchFloat64s(w.cum, rng.Float64()*w.cum[len(w.cum)-1])
+	if i == len(w.cum) {
+		i = len(w.cum) - 1
+	}
+	sample := make(map[string]string, len(w.rows[i]))
+	for name, v := range w.rows[i] {
+		sample[name] = v
+	}
+	return sample, nil
+}
+
+func containsValue(values []string, v string) bool {
+	for _, candidate := range values {
+		if candidate == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package transport
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yourneighborhoodchef/browserforge/fingerprint"
+	"github.com/yourneighborhoodchef/browserforge/internal/headers"
+)
+
+// maxHeadSize bounds the request head orderedConn buffers. Longer heads are
+// written as they are.
+const maxHeadSize = 1 << 20
+
+// orderedConn rewrites the head of every HTTP/1.1 request written to it so
+// that its header fields are in the browser's order. Request bodies, sent
+// with Content-Length, pass through unchanged, as do bytes that do not start
+// a request, such as a SOCKS handshake. After a CONNECT request or a chunked
+// body everything passes through.
+type orderedConn struct {
+	net.Conn
+	// tls is the connection's TLS layer, if it has one.
+	tls   *tls.Conn
+	order func(fingerprint.Headers) fingerprint.Headers
+
+	head        []byte
+	body        int64
+	passthrough bool
+}
+
+func (t *Transport) ordered(c net.Conn, tc *tls.Conn) *orderedConn {
+	return &orderedConn{
+		Conn: c,
+		tls:  tc,
+		order: func(hdrs fingerprint.Headers) fingerprint.Headers {
+			return t.generator.Order(t.fp, hdrs)
+		},
+	}
+}
+
+func (c *orderedConn) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		switch {
+		case c.passthrough:
+			if _, err := c.Conn.Write(p); err != nil {
+				return 0, err
+			}
+			return n, nil
+		case c.body > 0:
+			k := len(p)
+			if int64(k) > c.body {
+				k = int(c.body)
+			}
+			if _, err := c.Conn.Write(p[:k]); err != nil {
+				return 0, err
+			}
+			c.body -= int64(k)
+			p = p[k:]
+		case len(c.head) == 0 && (p[0] < 'A' || p[0] > 'Z'):
+			// Not a request line.
+			if _, err := c.Conn.Write(p); err != nil {
+				return 0, err
+			}
+			return n, nil
+		default:
+			c.head = append(c.head, p...)
+			end := bytes.Index(c.head, []byte("\r\n\r\n"))
+			if end < 0 {
+				if len(c.head) > maxHeadSize {
+					c.passthrough = true
+					head := c.head
+					c.head = nil
+					if _, err := c.Conn.Write(head); err != nil {
+						return 0, err
+					}
+				}
+				return n, nil
+			}
+			head, rest := c.head[:end+4], c.head[end+4:]
+			c.head = nil
+			if _, err := c.Conn.Write(c.reorder(head)); err != nil {
+				return 0, err
+			}
+			p = rest
+		}
+	}
+	return n, nil
+}
+
+// reorder returns head with its header fields sorted into the browser's
+// order, and sets up the passing through of the body that follows it.
+func (c *orderedConn) reorder(head []byte) []byte {
+	lines := strings.Split(string(head[:len(head)-4]), "\r\n")
+	if method, _, _ := strings.Cut(lines[0], " "); method == "CONNECT" {
+		c.passthrough = true
+		return head
+	}
+	hdrs := make(fingerprint.Headers, 0, len(lines)-1)
+	for _, line := range lines[1:] {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			c.passthrough = true
+			return head
+		}
+		hdrs = append(hdrs, headers.Header{Name: name, Value: strings.TrimLeft(value, " \t")})
+	}
+	if _, chunked := hdrs.Lookup("Transfer-Encoding"); chunked {
+		c.passthrough = true
+	} else if length, ok := hdrs.Lookup("Content-Length"); ok {
+		c.body, _ = strconv.ParseInt(length, 10, 64)
+	}
+
+	rank := make(map[string]int, len(hdrs))
+	for i, hdr := range c.order(hdrs) {
+		rank[hdr.Name] = i
+	}
+	sort.SliceStable(hdrs, func(i, j int) bool {
+		return rank[hdrs[i].Name] < rank[hdrs[j].Name]
+	})
+	var b strings.Builder
+	b.Grow(len(head))
+	b.WriteString(lines[0])
+	b.WriteString("\r\n")
+	for _, hdr := range hdrs {
+		b.WriteString(hdr.Name)
+		b.WriteString(": ")
+		b.WriteString(hdr.Value)
+		b.WriteString("\r\n")
+	}
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
+
+// dialTLS opens a TLS connection to addr, through the proxy for HTTPS
+// requests if there is one. HTTP/2 is offered for fingerprints of HTTP/2
+// browsers; connections speaking HTTP/1.1 have their request heads
+// reordered.
+func (t *Transport) dialTLS(ctx context.Context, network, addr string) (net.Conn, error) {
+	c, err := t.dialTarget(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{}
+	if t.base.TLSClientConfig != nil {
+		cfg = t.base.TLSClientConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		host, _, _ := net.SplitHostPort(addr)
+		cfg.ServerName = host
+	}
+	cfg.NextProtos = []string{"http/1.1"}
+	if _, http2 := t.fp.Headers.Lookup(":method"); http2 && !t.http1 {
+		cfg.NextProtos = []string{"h2", "http/1.1"}
+	}
+	tc := tls.Client(c, cfg)
+	if err := tc.HandshakeContext(ctx); err != nil {
+		c.Close()
+		return nil, err
+	}
+	if tc.ConnectionState().NegotiatedProtocol == "h2" {
+		return tc, nil
+	}
+	return t.ordered(tc, tc), nil
+}
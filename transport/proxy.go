@@ -0,0 +1,248 @@
+package transport
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// dialTarget opens a connection to addr for an HTTPS request, tunnelled
+// through the proxy the base transport picks for it.
+func (t *Transport) dialTarget(ctx context.Context, network, addr string) (net.Conn, error) {
+	var proxyURL *url.URL
+	if t.proxy != nil {
+		var err error
+		req := &http.Request{Method: http.MethodGet, URL: &url.URL{Scheme: "https", Host: addr}, Header: make(http.Header)}
+		if proxyURL, err = t.proxy(req.WithContext(ctx)); err != nil {
+			return nil, fmt.Errorf("choosing proxy: %w", err)
+		}
+	}
+	if proxyURL == nil {
+		return t.dial(ctx, network, addr)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https":
+		return t.dialConnect(ctx, network, proxyURL, addr)
+	case "socks5", "socks5h":
+		return t.dialSOCKS5(ctx, network, proxyURL, addr)
+	}
+	return nil, fmt.Errorf("unsupported proxy scheme %q", proxyURL.Scheme)
+}
+
+func proxyAddr(u *url.URL) string {
+	if u.Port() != "" {
+		return u.Host
+	}
+	port := "80"
+	switch u.Scheme {
+	case "https":
+		port = "443"
+	case "socks5", "socks5h":
+		port = "1080"
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// dialConnect opens a tunnel to addr with an HTTP CONNECT request.
+func (t *Transport) dialConnect(ctx context.Context, network string, proxyURL *url.URL, addr string) (net.Conn, error) {
+	c, err := t.dial(ctx, network, proxyAddr(proxyURL))
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme == "https" {
+		cfg := &tls.Config{}
+		if t.base.TLSClientConfig != nil {
+			cfg = t.base.TLSClientConfig.Clone()
+		}
+		cfg.ServerName = proxyURL.Hostname()
+		cfg.NextProtos = nil
+		tc := tls.Client(c, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			c.Close()
+			return nil, err
+		}
+		c = tc
+	}
+
+	connectReq := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: t.base.ProxyConnectHeader.Clone(),
+	}
+	if connectReq.Header == nil {
+		connectReq.Header = make(http.Header)
+	}
+	if connectReq.Header.Get("User-Agent") == "" {
+		connectReq.Header.Set("User-Agent", t.fp.Navigator.UserAgent)
+	}
+	if user := proxyURL.User; user != nil {
+		password, _ := user.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		connectReq.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+
+	stop := closeOnDone(ctx, c)
+	defer stop()
+	if err := connectReq.Write(c); err != nil {
+		c.Close()
+		return nil, ctxErr(ctx, err)
+	}
+	// The proxy sends nothing after its response until the TLS handshake
+	// starts, so the reader cannot hold back bytes of the tunnel.
+	resp, err := http.ReadResponse(bufio.NewReader(c), connectReq)
+	if err != nil {
+		c.Close()
+		return nil, ctxErr(ctx, err)
+	}
+	// A successful CONNECT response has no body; reading one would block
+	// on the tunnel.
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		c.Close()
+		return nil, fmt.Errorf("proxy refused CONNECT to %s: %s", addr, resp.Status)
+	}
+	return c, nil
+}
+
+// dialSOCKS5 opens a tunnel to addr through a SOCKS5 proxy, letting the
+// proxy resolve the host name. IP addresses are sent as such.
+func (t *Transport) dialSOCKS5(ctx context.Context, network string, proxyURL *url.URL, addr string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || len(host) > 255 {
+		return nil, fmt.Errorf("invalid address %q", addr)
+	}
+	c, err := t.dial(ctx, network, proxyAddr(proxyURL))
+	if err != nil {
+		return nil, err
+	}
+	stop := closeOnDone(ctx, c)
+	defer stop()
+	if err := socks5Handshake(c, proxyURL.User, host, port); err != nil {
+		c.Close()
+		return nil, ctxErr(ctx, fmt.Errorf("socks5 proxy: %w", err))
+	}
+	return c, nil
+}
+
+func socks5Handshake(rw io.ReadWriter, user *url.Userinfo, host string, port int) error {
+	const (
+		version      = 5
+		noAuth       = 0
+		passwordAuth = 2
+	)
+	methods := []byte{noAuth}
+	if user != nil {
+		methods = append(methods, passwordAuth)
+	}
+	if _, err := rw.Write(append([]byte{version, byte(len(methods))}, methods...)); err != nil {
+		return err
+	}
+	var reply [2]byte
+	if _, err := io.ReadFull(rw, reply[:]); err != nil {
+		return err
+	}
+	if reply[0] != version {
+		return fmt.Errorf("unexpected version %d", reply[0])
+	}
+	switch reply[1] {
+	case noAuth:
+	case passwordAuth:
+		if user == nil {
+			return errors.New("proxy requires a password")
+		}
+		password, _ := user.Password()
+		if len(user.Username()) > 255 || len(password) > 255 {
+			return errors.New("user name or password too long")
+		}
+		msg := []byte{1, byte(len(user.Username()))}
+		msg = append(msg, user.Username()...)
+		msg = append(msg, byte(len(password)))
+		msg = append(msg, password...)
+		if _, err := rw.Write(msg); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(rw, reply[:]); err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("authentication failed")
+		}
+	default:
+		return errors.New("no acceptable authentication method")
+	}
+
+	msg := []byte{version, 1, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		msg = append(msg, 3, byte(len(host)))
+		msg = append(msg, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		msg = append(msg, 1)
+		msg = append(msg, ip4...)
+	} else {
+		msg = append(msg, 4)
+		msg = append(msg, ip...)
+	}
+	msg = append(msg, byte(port>>8), byte(port))
+	if _, err := rw.Write(msg); err != nil {
+		return err
+	}
+	var head [4]byte
+	if _, err := io.ReadFull(rw, head[:]); err != nil {
+		return err
+	}
+	if head[1] != 0 {
+		return fmt.Errorf("connect failed with code %d", head[1])
+	}
+	var skip int
+	switch head[3] {
+	case 1:
+		skip = net.IPv4len
+	case 4:
+		skip = net.IPv6len
+	case 3:
+		var n [1]byte
+		if _, err := io.ReadFull(rw, n[:]); err != nil {
+			return err
+		}
+		skip = int(n[0])
+	default:
+		return fmt.Errorf("unknown address type %d", head[3])
+	}
+	_, err := io.ReadFull(rw, make([]byte, skip+2))
+	return err
+}
+
+// closeOnDone closes c if ctx is done before stop is called, which unblocks
+// the reads and writes of a handshake.
+func closeOnDone(ctx context.Context, c net.Conn) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.Close()
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+func ctxErr(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
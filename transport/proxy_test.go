@@ -0,0 +1,303 @@
+package transport
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSOCKS5Handshake(t *testing.T) {
+	var (
+		noAuth     = []byte{5, 0}
+		succeeded  = []byte{5, 0, 0, 1, 192, 0, 2, 1, 0x04, 0x38}
+		greeting   = []byte{5, 1, 0}
+		passwords  = []byte{5, 2, 0, 2}
+		connectDom = append(append([]byte{5, 1, 0, 3, 11}, "example.com"...), 1, 187)
+	)
+	cat := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	for _, tt := range []struct {
+		name    string
+		user    *url.Userinfo
+		host    string
+		replies []byte
+		wrote   []byte
+		err     string
+	}{
+		{
+			name:    "no auth, domain",
+			host:    "example.com",
+			replies: cat(noAuth, succeeded),
+			wrote:   cat(greeting, connectDom),
+		},
+		{
+			name:    "password",
+			user:    url.UserPassword("user", "pw"),
+			host:    "example.com",
+			replies: cat([]byte{5, 2}, []byte{1, 0}, succeeded),
+			wrote:   cat(passwords, []byte{1, 4}, []byte("user"), []byte{2}, []byte("pw"), connectDom),
+		},
+		{
+			name:    "user offered but not required",
+			user:    url.User("user"),
+			host:    "example.com",
+			replies: cat(noAuth, succeeded),
+			wrote:   cat(passwords, connectDom),
+		},
+		{
+			name:    "IPv4",
+			host:    "192.0.2.7",
+			replies: cat(noAuth, succeeded),
+			wrote:   cat(greeting, []byte{5, 1, 0, 1, 192, 0, 2, 7, 1, 187}),
+		},
+		{
+			name:    "IPv6",
+			host:    "2001:db8::1",
+			replies: cat(noAuth, []byte{5, 0, 0, 4}, net.ParseIP("2001:db8::2"), []byte{0x04, 0x38}),
+			wrote:   cat(greeting, []byte{5, 1, 0, 4}, net.ParseIP("2001:db8::1"), []byte{1, 187}),
+		},
+		{
+			name:    "domain in reply",
+			host:    "example.com",
+			replies: cat(noAuth, []byte{5, 0, 0, 3, 5}, []byte("proxy"), []byte{0x04, 0x38}),
+			wrote:   cat(greeting, connectDom),
+		},
+		{
+			name:    "wrong version",
+			host:    "example.com",
+			replies: []byte{4, 0},
+			err:     "unexpected version 4",
+		},
+		{
+			name:    "no acceptable method",
+			host:    "example.com",
+			replies: []byte{5, 0xff},
+			err:     "no acceptable authentication method",
+		},
+		{
+			name:    "password required",
+			host:    "example.com",
+			replies: []byte{5, 2},
+			err:     "proxy requires a password",
+		},
+		{
+			name:    "authentication failed",
+			user:    url.UserPassword("user", "wrong"),
+			host:    "example.com",
+			replies: []byte{5, 2, 1, 1},
+			err:     "authentication failed",
+		},
+		{
+			name:    "connection refused",
+			host:    "example.com",
+			replies: cat(noAuth, []byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0}),
+			err:     "connect failed with code 5",
+		},
+		{
+			name:    "unknown address type",
+			host:    "example.com",
+			replies: cat(noAuth, []byte{5, 0, 0, 9}),
+			err:     "unknown address type 9",
+		},
+		{
+			name:    "truncated reply",
+			host:    "example.com",
+			replies: cat(noAuth, []byte{5, 0, 0, 1, 192, 0}),
+			err:     io.ErrUnexpectedEOF.Error(),
+		},
+	} {
+		// Bytes after the reply belong to the tunnel and must not be read.
+		tunnel := "tunnel"
+		if tt.err != "" {
+			tunnel = ""
+		}
+		r := bytes.NewReader(append(tt.replies, tunnel...))
+		var w bytes.Buffer
+		err := socks5Handshake(struct {
+			io.Reader
+			io.Writer
+		}{r, &w}, tt.user, tt.host, 443)
+
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !bytes.Equal(w.Bytes(), tt.wrote) {
+			t.Errorf("%s: wrote\n%v\nwant\n%v", tt.name, w.Bytes(), tt.wrote)
+		}
+		if rest, _ := io.ReadAll(r); string(rest) != tunnel {
+			t.Errorf("%s: left %q unread, want %q", tt.name, rest, tunnel)
+		}
+	}
+}
+
+// socks5Server is a SOCKS5 proxy that accepts the user name and password
+// of want, or no authentication if want is nil.
+type socks5Server struct {
+	net.Listener
+	want *url.Userinfo
+
+	mu      sync.Mutex
+	targets []string
+}
+
+func newSOCKS5Server(t *testing.T, want *url.Userinfo) *socks5Server {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &socks5Server{Listener: l, want: want}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *socks5Server) serve(c net.Conn) {
+	defer c.Close()
+	var head [2]byte
+	if _, err := io.ReadFull(c, head[:]); err != nil {
+		return
+	}
+	methods := make([]byte, head[1])
+	if _, err := io.ReadFull(c, methods); err != nil {
+		return
+	}
+	method := byte(0)
+	if s.want != nil {
+		method = 2
+	}
+	if bytes.IndexByte(methods, method) < 0 {
+		c.Write([]byte{5, 0xff})
+		return
+	}
+	c.Write([]byte{5, method})
+	if method == 2 {
+		var n [1]byte
+		io.ReadFull(c, head[:1])
+		io.ReadFull(c, n[:])
+		user := make([]byte, n[0])
+		io.ReadFull(c, user)
+		io.ReadFull(c, n[:])
+		password := make([]byte, n[0])
+		io.ReadFull(c, password)
+		wantPassword, _ := s.want.Password()
+		if string(user) != s.want.Username() || string(password) != wantPassword {
+			c.Write([]byte{1, 1})
+			return
+		}
+		c.Write([]byte{1, 0})
+	}
+
+	var req [4]byte
+	if _, err := io.ReadFull(c, req[:]); err != nil {
+		return
+	}
+	var host string
+	switch req[3] {
+	case 1, 4:
+		ip := make(net.IP, net.IPv4len)
+		if req[3] == 4 {
+			ip = make(net.IP, net.IPv6len)
+		}
+		io.ReadFull(c, ip)
+		host = ip.String()
+	case 3:
+		var n [1]byte
+		io.ReadFull(c, n[:])
+		name := make([]byte, n[0])
+		io.ReadFull(c, name)
+		host = string(name)
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(c, port[:]); err != nil {
+		return
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:]))))
+	s.mu.Lock()
+	s.targets = append(s.targets, target)
+	s.mu.Unlock()
+
+	tc, err := net.Dial("tcp", target)
+	if err != nil {
+		c.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return
+	}
+	defer tc.Close()
+	c.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 0})
+	go io.Copy(tc, c)
+	io.Copy(c, tc)
+}
+
+func TestSOCKS5Proxy(t *testing.T) {
+	g, fp := newFingerprint(t, "1")
+	serverURL, pool, rec := newTLSServer(t, http.HandlerFunc(echo))
+	target := strings.TrimPrefix(serverURL, "https://")
+
+	for _, tt := range []struct {
+		name  string
+		want  *url.Userinfo
+		user  *url.Userinfo
+		fails bool
+	}{
+		{name: "no auth"},
+		{name: "password", want: url.UserPassword("user", "pw"), user: url.UserPassword("user", "pw")},
+		{name: "wrong password", want: url.UserPassword("user", "pw"), user: url.UserPassword("user", "nope"), fails: true},
+	} {
+		proxy := newSOCKS5Server(t, tt.want)
+		proxyURL := &url.URL{Scheme: "socks5", Host: proxy.Addr().String(), User: tt.user}
+		tr, err := New(fp,
+			WithGenerator(g),
+			WithBase(&http.Transport{Proxy: http.ProxyURL(proxyURL)}),
+			WithTLSConfig(&tls.Config{RootCAs: pool}),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := (&http.Client{Transport: tr}).Get(serverURL)
+		if tt.fails {
+			if err == nil || !strings.Contains(err.Error(), "socks5 proxy: authentication failed") {
+				t.Errorf("%s: error %v, want a failed authentication", tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		resp.Body.Close()
+
+		proxy.mu.Lock()
+		if len(proxy.targets) != 1 || proxy.targets[0] != target {
+			t.Errorf("%s: proxy connected to %q, want %s", tt.name, proxy.targets, target)
+		}
+		proxy.mu.Unlock()
+	}
+	if heads := wireHeads(t, rec.bytes()); len(heads) == 2 {
+		checkOrder(t, g, fp, heads[1])
+	} else {
+		t.Errorf("got %d requests through the tunnels, want 2", len(heads))
+	}
+}
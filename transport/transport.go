@@ -0,0 +1,345 @@
+package transport
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/yourneighborhoodchef/browserforge/fingerprint"
+	"github.com/yourneighborhoodchef/browserforge/internal/headers"
+)
+
+// Transport is an http.RoundTripper that sends requests with the headers of
+// a fingerprint, in the order its browser puts them on the wire.
+//
+// Requests go through an http.Transport, which keeps its connection pooling,
+// proxies, timeouts and HTTP/2 support. net/http writes HTTP/1.1 header
+// fields sorted by name, so Transport dials the connections itself and
+// reorders each request head as it is written. Over HTTP/2, which is
+// negotiated for fingerprints of HTTP/2 browsers, net/http chooses the field
+// order; WithHTTP1 keeps the browser's order by always speaking HTTP/1.1.
+// Only the header layer is imitated: the TLS handshake is Go's.
+//
+// Transport is safe for concurrent use.
+type Transport struct {
+	fp        *fingerprint.Fingerprint
+	generator *fingerprint.Generator
+	base      *http.Transport
+	dial      func(ctx context.Context, network, addr string) (net.Conn, error)
+	proxy     func(*http.Request) (*url.URL, error)
+	tlsConfig *tls.Config
+	http1     bool
+	decoders  map[string]Decoder
+}
+
+// Decoder returns a reader that decodes a response body sent with a
+// Content-Encoding.
+type Decoder func(io.Reader) (io.Reader, error)
+
+type Option func(*Transport) error
+
+// WithGenerator sets the generator used to order headers. By default a
+// generator with the embedded data is used.
+func WithGenerator(g *fingerprint.Generator) Option {
+	return func(t *Transport) error {
+		if g == nil {
+			return fmt.Errorf("invalid generator: nil")
+		}
+		t.generator = g
+		return nil
+	}
+}
+
+// WithBase sets the http.Transport requests are sent through, for its proxy,
+// timeouts, pool limits and TLS configuration. It is cloned; its DialTLS and
+// DialTLSContext functions are replaced and compression is disabled. By
+// default a clone of http.DefaultTransport is used.
+func WithBase(base *http.Transport) Option {
+	return func(t *Transport) error {
+		if base == nil {
+			return fmt.Errorf("invalid base transport: nil")
+		}
+		t.base = base.Clone()
+		return nil
+	}
+}
+
+// WithDialContext sets the function that opens TCP connections, to targets
+// and to proxies.
+func WithDialContext(dial func(ctx context.Context, network, addr string) (net.Conn, error)) Option {
+	return func(t *Transport) error {
+		if dial == nil {
+			return fmt.Errorf("invalid dial function: nil")
+		}
+		t.dial = dial
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration for HTTPS connections. The
+// configuration is cloned per connection and its NextProtos are replaced.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(t *Transport) error {
+		if cfg == nil {
+			return fmt.Errorf("invalid TLS configuration: nil")
+		}
+		t.tlsConfig = cfg
+		return nil
+	}
+}
+
+// WithHTTP1 speaks HTTP/1.1 to every server, so that the header order is the
+// browser's even for fingerprints of HTTP/2 browsers.
+func WithHTTP1() Option {
+	return func(t *Transport) error {
+		t.http1 = true
+		return nil
+	}
+}
+
+// WithDecoder sets the decoder for responses with the content coding
+// coding, such as "br" or "zstd". Decoders for gzip and deflate are built in.
+func WithDecoder(coding string, d Decoder) Option {
+	return func(t *Transport) error {
+		if coding == "" {
+			return fmt.Errorf("invalid content coding: empty")
+		}
+		if d == nil {
+			return fmt.Errorf("invalid decoder for %q: nil", coding)
+		}
+		t.decoders[strings.ToLower(coding)] = d
+		return nil
+	}
+}
+
+func New(fp *fingerprint.Fingerprint, opts ...Option) (*Transport, error) {
+	if fp == nil {
+		return nil, fmt.Errorf("invalid fingerprint: nil")
+	}
+	t := &Transport{
+		fp:   fp,
+		base: http.DefaultTransport.(*http.Transport).Clone(),
+		decoders: map[string]Decoder{
+			"gzip":    func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+			"deflate": func(r io.Reader) (io.Reader, error) { return zlib.NewReader(r) },
+		},
+	}
+	for _, opt := range opts {
+		if err := opt(t); err != nil {
+			return nil, err
+		}
+	}
+	if t.generator == nil {
+		g, err := fingerprint.New()
+		if err != nil {
+			return nil, err
+		}
+		t.generator = g
+	}
+	if t.tlsConfig != nil {
+		t.base.TLSClientConfig = t.tlsConfig
+	}
+	if t.dial == nil {
+		t.dial = t.base.DialContext
+	}
+	if t.dial == nil {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		t.dial = dialer.DialContext
+	}
+
+	// HTTPS requests are tunnelled through the proxy by dialTLS, so that
+	// net/http does not wrap the connection in its own TLS.
+	t.proxy = t.base.Proxy
+	t.base.Proxy = func(req *http.Request) (*url.URL, error) {
+		if t.proxy == nil || req.URL.Scheme == "https" {
+			return nil, nil
+		}
+		return t.proxy(req)
+	}
+	t.base.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		c, err := t.dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return t.ordered(c, nil), nil
+	}
+	t.base.DialTLS = nil
+	t.base.DialTLSContext = t.dialTLS
+	t.base.ForceAttemptHTTP2 = !t.http1
+	t.base.DisableCompression = true
+	return t, nil
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx that carries rc. Requests made with the
+// returned context get the Sec-Fetch-*, Accept and related headers for rc's
+// destination, site and user activation; URL and Method always come from the
+// request itself.
+func NewContext(ctx context.Context, rc fingerprint.RequestContext) context.Context {
+	return context.WithValue(ctx, contextKey{}, rc)
+}
+
+func FromContext(ctx context.Context) (fingerprint.RequestContext, bool) {
+	rc, ok := ctx.Value(contextKey{}).(fingerprint.RequestContext)
+	return rc, ok
+}
+
+// RoundTrip sends req with the fingerprint's headers. Headers set on req
+// override the fingerprint's value in place; a Referer header names the page
+// making the request and is trimmed like a browser would trim it.
+//
+// The fingerprint's Accept-Encoding is sent unchanged. Unless req sets its
+// own, responses in a coding Transport has a decoder for are decompressed;
+// others, such as br without WithDecoder, keep their body and
+// Content-Encoding as sent.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL == nil {
+		return nil, errors.New("request has no URL")
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported protocol scheme %q", req.URL.Scheme)
+	}
+	body, err := readBody(req)
+	if err != nil {
+		return nil, err
+	}
+	hdrs, err := t.headers(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var conn net.Conn
+	ctx := httptrace.WithClientTrace(req.Context(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) { conn = info.Conn },
+	})
+	out := req.Clone(ctx)
+	out.Header = make(http.Header, len(hdrs))
+	for _, hdr := range hdrs {
+		// Keys are stored as spelled, which net/http writes unchanged.
+		out.Header[hdr.Name] = []string{hdr.Value}
+	}
+	// Browsers always know the length of what they upload, so bodies are
+	// sent with Content-Length rather than chunked.
+	out.ContentLength = int64(len(body))
+	out.Body, out.GetBody = nil, nil
+	if body != nil {
+		out.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+		out.Body, _ = out.GetBody()
+	}
+
+	resp, err := t.base.RoundTrip(out)
+	if err != nil {
+		return nil, err
+	}
+	resp.Request = req
+	if oc, ok := conn.(*orderedConn); ok && resp.TLS == nil && oc.tls != nil {
+		state := oc.tls.ConnectionState()
+		resp.TLS = &state
+	}
+	if _, custom := req.Header["Accept-Encoding"]; !custom {
+		t.decompress(resp)
+	}
+	return resp, nil
+}
+
+// headers builds the header list for req: the fingerprint's headers for the
+// request, overridden by those set on req. Host and Content-Length are left
+// to net/http.
+func (t *Transport) headers(req *http.Request) (fingerprint.Headers, error) {
+	rc, _ := FromContext(req.Context())
+	rc.URL = req.URL.String()
+	rc.Method = req.Method
+	if rc.Referer == "" {
+		rc.Referer = req.Header.Get("Referer")
+	}
+	hdrs, err := t.generator.HeadersFor(t.fp, rc)
+	if err != nil {
+		return nil, err
+	}
+	for i := range hdrs {
+		hdrs[i].Name = headers.Pascalize(hdrs[i].Name)
+	}
+	// Pseudo-header fields are written by net/http's HTTP/2 client.
+	for _, name := range []string{":method", ":authority", ":scheme", ":path", "Host", "Content-Length"} {
+		hdrs.Del(name)
+	}
+
+	for name, values := range req.Header {
+		if strings.EqualFold(name, "Referer") || strings.EqualFold(name, "Host") || strings.EqualFold(name, "Content-Length") {
+			continue
+		}
+		sep := ", "
+		if strings.EqualFold(name, "Cookie") {
+			sep = "; "
+		}
+		hdrs.Set(name, strings.Join(values, sep))
+	}
+	return hdrs, nil
+}
+
+// CloseIdleConnections closes connections kept for reuse. http.Client calls
+// it from its own CloseIdleConnections.
+func (t *Transport) CloseIdleConnections() {
+	t.base.CloseIdleConnections()
+}
+
+func readBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	defer req.Body.Close()
+	var buf bytes.Buffer
+	if _, err := buf.ReadFrom(req.Body); err != nil {
+		return nil, fmt.Errorf("reading request body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressedBody decodes lazily so that a failing header is reported from
+// Read, like net/http does.
+type decompressedBody struct {
+	body      io.ReadCloser
+	newReader Decoder
+	r         io.Reader
+	err       error
+}
+
+func (d *decompressedBody) Read(p []byte) (int, error) {
+	if d.r == nil && d.err == nil {
+		d.r, d.err = d.newReader(d.body)
+	}
+	if d.err != nil {
+		return 0, d.err
+	}
+	return d.r.Read(p)
+}
+
+func (d *decompressedBody) Close() error {
+	return d.body.Close()
+}
+
+func (t *Transport) decompress(resp *http.Response) {
+	newReader := t.decoders[strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))]
+	if newReader == nil {
+		return
+	}
+	resp.Body = &decompressedBody{body: resp.Body, newReader: newReader}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
@@ -0,0 +1,386 @@
+package transport
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/yourneighborhoodchef/browserforge/fingerprint"
+	"github.com/yourneighborhoodchef/browserforge/internal/fixtures"
+)
+
+func newFingerprint(t *testing.T, httpVersion string) (*fingerprint.Generator, *fingerprint.Fingerprint) {
+	t.Helper()
+	g, err := fingerprint.NewWithOptions(
+		fingerprint.WithDataSource(fixtures.Source),
+		fingerprint.WithSeed(1),
+		fingerprint.WithBrowser("chrome"),
+		fingerprint.WithHTTPVersion(httpVersion),
+	)
+	if err != nil {
+		t.Fatalf("NewWithOptions: %v", err)
+	}
+	fp, err := g.Generate()
+	if err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	return g, fp
+}
+
+// recordingListener keeps a copy of everything its connections read.
+type recordingListener struct {
+	net.Listener
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *recordingListener) Accept() (net.Conn, error) {
+	c, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &recordingConn{Conn: c, l: l}, nil
+}
+
+func (l *recordingListener) bytes() []byte {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]byte(nil), l.buf.Bytes()...)
+}
+
+type recordingConn struct {
+	net.Conn
+	l *recordingListener
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.l.mu.Lock()
+	c.l.buf.Write(p[:n])
+	c.l.mu.Unlock()
+	return n, err
+}
+
+// wireHeads splits raw HTTP/1.1 requests into the field names of each head.
+func wireHeads(t *testing.T, raw []byte) [][]string {
+	t.Helper()
+	var heads [][]string
+	r := bufio.NewReader(bytes.NewReader(raw))
+	for {
+		line, err := r.ReadString('\n')
+		if err == io.EOF {
+			return heads
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(line, " HTTP/1.1\r\n") {
+			t.Fatalf("unexpected request line %q", line)
+		}
+		var names []string
+		length := 0
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if line == "\r\n" {
+				break
+			}
+			name, value, _ := strings.Cut(strings.TrimSuffix(line, "\r\n"), ": ")
+			names = append(names, name)
+			if name == "Content-Length" {
+				length, _ = strconv.Atoi(value)
+			}
+		}
+		if _, err := r.Discard(length); err != nil {
+			t.Fatal(err)
+		}
+		heads = append(heads, names)
+	}
+}
+
+// checkOrder fails unless names are in the order g gives fp's browser.
+func checkOrder(t *testing.T, g *fingerprint.Generator, fp *fingerprint.Fingerprint, names []string) {
+	t.Helper()
+	hdrs := make(fingerprint.Headers, 0, len(names))
+	for _, name := range names {
+		hdrs.Set(name, "x")
+	}
+	want := g.Order(fp, hdrs).Names()
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Errorf("header order on the wire:\n%v\nwant\n%v", names, want)
+	}
+}
+
+func echo(w http.ResponseWriter, r *http.Request) {
+	io.Copy(w, r.Body)
+}
+
+func TestHeaderOrderOnTheWire(t *testing.T) {
+	g, fp := newFingerprint(t, "1")
+	server := httptest.NewUnstartedServer(http.HandlerFunc(echo))
+	rec := &recordingListener{Listener: server.Listener}
+	server.Listener = rec
+	server.Start()
+	defer server.Close()
+
+	tr, err := New(fp, WithGenerator(g))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: tr}
+	for _, body := range []string{"", "first body", "", "second"} {
+		method := http.MethodGet
+		if body != "" {
+			method = http.MethodPost
+		}
+		req, _ := http.NewRequest(method, server.URL+"/path?q=1", strings.NewReader(body))
+		req.Header.Set("X-Extra", "1")
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", method, err)
+		}
+		got, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(got) != body {
+			t.Errorf("echoed body %q, want %q", got, body)
+		}
+	}
+
+	heads := wireHeads(t, rec.bytes())
+	if len(heads) != 4 {
+		t.Fatalf("got %d requests on the wire, want 4", len(heads))
+	}
+	for _, names := range heads {
+		checkOrder(t, g, fp, names)
+		if names[0] != "Host" {
+			t.Errorf("first field %q, want Host", names[0])
+		}
+	}
+}
+
+// newTLSServer serves handler over TLS with the certificate of an httptest
+// server, recording the decrypted requests.
+func newTLSServer(t *testing.T, handler http.Handler) (string, *x509.CertPool, *recordingListener) {
+	t.Helper()
+	ts := httptest.NewTLSServer(handler)
+	t.Cleanup(ts.Close)
+	cfg := ts.TLS.Clone()
+	cfg.NextProtos = []string{"http/1.1"}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := &recordingListener{Listener: tls.NewListener(ln, cfg)}
+	srv := &http.Server{Handler: handler}
+	go srv.Serve(rec)
+	t.Cleanup(func() { srv.Close() })
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	return "https://" + ln.Addr().String(), pool, rec
+}
+
+func TestHeaderOrderOverTLS(t *testing.T) {
+	g, fp := newFingerprint(t, "2")
+	serverURL, pool, rec := newTLSServer(t, http.HandlerFunc(echo))
+
+	tr, err := New(fp, WithGenerator(g), WithTLSConfig(&tls.Config{RootCAs: pool}), WithHTTP1())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: tr}).Get(serverURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.TLS == nil {
+		t.Error("response has no TLS state")
+	}
+	heads := wireHeads(t, rec.bytes())
+	if len(heads) != 1 {
+		t.Fatalf("got %d requests on the wire, want 1", len(heads))
+	}
+	checkOrder(t, g, fp, heads[0])
+}
+
+func TestHTTP2(t *testing.T) {
+	g, fp := newFingerprint(t, "2")
+	server := httptest.NewUnstartedServer(http.HandlerFunc(echo))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	for _, tc := range []struct {
+		opts  []Option
+		proto int
+	}{
+		{nil, 2},
+		{[]Option{WithHTTP1()}, 1},
+	} {
+		opts := append([]Option{WithGenerator(g), WithTLSConfig(&tls.Config{RootCAs: pool})}, tc.opts...)
+		tr, err := New(fp, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := (&http.Client{Transport: tr}).Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.ProtoMajor != tc.proto {
+			t.Errorf("spoke HTTP/%d, want HTTP/%d", resp.ProtoMajor, tc.proto)
+		}
+	}
+}
+
+func TestConnectProxy(t *testing.T) {
+	g, fp := newFingerprint(t, "1")
+	serverURL, pool, rec := newTLSServer(t, http.HandlerFunc(echo))
+
+	var mu sync.Mutex
+	var connects []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "CONNECT only", http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		connects = append(connects, r.Host+" "+r.Header.Get("User-Agent"))
+		mu.Unlock()
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		client, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			target.Close()
+			return
+		}
+		go func() {
+			io.Copy(target, client)
+			target.Close()
+		}()
+		io.Copy(client, target)
+		client.Close()
+	}))
+	defer proxy.Close()
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	tr, err := New(fp,
+		WithGenerator(g),
+		WithBase(&http.Transport{Proxy: http.ProxyURL(proxyURL)}),
+		WithTLSConfig(&tls.Config{RootCAs: pool}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := (&http.Client{Transport: tr}).Get(serverURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	host := strings.TrimPrefix(serverURL, "https://")
+	if len(connects) != 1 || connects[0] != host+" "+fp.Navigator.UserAgent {
+		t.Errorf("proxy saw CONNECTs %q, want one to %s", connects, host)
+	}
+	if heads := wireHeads(t, rec.bytes()); len(heads) == 1 {
+		checkOrder(t, g, fp, heads[0])
+	} else {
+		t.Errorf("got %d requests through the tunnel, want 1", len(heads))
+	}
+}
+
+func TestAcceptEncoding(t *testing.T) {
+	g, fp := newFingerprint(t, "1")
+	const acceptEncoding = "gzip, deflate, br, zstd"
+	fp.Headers.Set("Accept-Encoding", acceptEncoding)
+
+	var mu sync.Mutex
+	var seen [][]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen = append(seen, r.Header["Accept-Encoding"])
+		mu.Unlock()
+		// The coding is named by the query; the body is gzip in every case.
+		w.Header().Set("Content-Encoding", r.URL.Query().Get("coding"))
+		zw := gzip.NewWriter(w)
+		io.WriteString(zw, "hello")
+		zw.Close()
+	}))
+	defer server.Close()
+
+	gunzip := func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }
+	for _, tt := range []struct {
+		coding  string
+		custom  string
+		decoder bool
+		decoded bool
+	}{
+		{coding: "gzip", decoded: true},
+		{coding: "br"},
+		{coding: "br", decoder: true, decoded: true},
+		{coding: "gzip", custom: "br"},
+	} {
+		opts := []Option{WithGenerator(g)}
+		if tt.decoder {
+			opts = append(opts, WithDecoder(tt.coding, gunzip))
+		}
+		tr, err := New(fp, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/?coding="+tt.coding, nil)
+		if tt.custom != "" {
+			req.Header.Set("Accept-Encoding", tt.custom)
+		}
+		resp, err := (&http.Client{Transport: tr}).Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if decoded := string(body) == "hello"; decoded != tt.decoded {
+			t.Errorf("%+v: body %q, decoded %v", tt, body, decoded)
+		}
+		wantEncoding := tt.coding
+		if tt.decoded {
+			wantEncoding = ""
+		}
+		if got := resp.Header.Get("Content-Encoding"); got != wantEncoding {
+			t.Errorf("%+v: Content-Encoding %q, want %q", tt, got, wantEncoding)
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{acceptEncoding, acceptEncoding, acceptEncoding, "br"}
+	if len(seen) != len(want) {
+		t.Fatalf("server saw %d requests, want %d", len(seen), len(want))
+	}
+	for i, values := range seen {
+		if len(values) != 1 || values[0] != want[i] {
+			t.Errorf("request %d: server saw Accept-Encoding %q, want %q", i, values, want[i])
+		}
+	}
+}